@@ -0,0 +1,189 @@
+package main
+
+import (
+	. "github.com/Al2Klimov/go-monplug-utils"
+	"strings"
+)
+
+// featureReading is what checkLinuxSensors has read from a single feature.
+type featureReading struct {
+	chip     string
+	adapter  string
+	name     string
+	label    string
+	kind     string
+	perfdata PerfdataCollection
+	hasAlarm bool
+	hasFault bool
+
+	// isImplausible tells whether the input is out of the plausible range.
+	isImplausible bool
+}
+
+// get returns the perfdata of the given subfeature (e.g. "input") if read (and plausible).
+func (fr *featureReading) get(subfeature string) (Perfdata, bool) {
+	if subfeature == "input" && fr.isImplausible {
+		return Perfdata{}, false
+	}
+
+	suffix := "::" + subfeature
+
+	for _, pd := range fr.perfdata {
+		if strings.HasSuffix(pd.Label, suffix) {
+			return pd, true
+		}
+	}
+
+	return Perfdata{}, false
+}
+
+// isInputLabel tells whether a perfdata label is the one of a feature's input.
+func isInputLabel(label string) bool {
+	return strings.HasSuffix(label, "::input")
+}
+
+// featureUnits maps "chip::feature" to the units of the features' values in the long output.
+func featureUnits(features []featureReading) map[string]string {
+	units := make(map[string]string, len(features))
+
+	for i := range features {
+		units[pdl(features[i].chip, features[i].name)] = kindUnits[features[i].kind]
+	}
+
+	return units
+}
+
+// featureOf returns the "chip::feature" part of a "chip::feature::subfeature" perfdata label.
+func featureOf(label string) string {
+	if sep := strings.LastIndex(label, "::"); sep >= 0 {
+		return label[:sep]
+	}
+
+	return label
+}
+
+// cpuTempDrivers are the chip prefixes of the drivers reporting CPU (core) temperatures.
+var cpuTempDrivers = map[string]struct{}{"coretemp": {}, "k10temp": {}, "zenpower": {}}
+
+var chipTempMaxWarn = thresholdFlag("chip-temp-max-warn", "Warning threshold for the highest temperature per chip")
+var chipTempMaxCrit = thresholdFlag("chip-temp-max-crit", "Critical threshold for the highest temperature per chip")
+var chipFanMinWarn = thresholdFlag("chip-fan-min-warn", "Warning threshold for the lowest fan speed per chip")
+var chipFanMinCrit = thresholdFlag("chip-fan-min-crit", "Critical threshold for the lowest fan speed per chip")
+var cpuTempMaxWarn = thresholdFlag("cpu-temp-max-warn", "Warning threshold for the highest CPU temperature")
+var cpuTempMaxCrit = thresholdFlag("cpu-temp-max-crit", "Critical threshold for the highest CPU temperature")
+var powerTotalWarn = thresholdFlag("power-total-warn", "Warning threshold for the total power")
+var powerTotalCrit = thresholdFlag("power-total-crit", "Critical threshold for the total power")
+
+// aggregate derives per chip and per host perfdata from the features' inputs.
+// The latter ones are omitted if only some chips are read.
+func aggregate(features []featureReading) (aggregates PerfdataCollection) {
+	chips := []string{}
+	chipsSeen := map[string]struct{}{}
+	chipTempMax := map[string]float64{}
+	chipFanMin := map[string]float64{}
+	cpuTempMax := negInf
+	powerTotal := 0.0
+	hasPower := false
+
+	for i := range features {
+		feature := &features[i]
+
+		if _, seen := chipsSeen[feature.chip]; !seen {
+			chipsSeen[feature.chip] = struct{}{}
+			chips = append(chips, feature.chip)
+		}
+
+		switch feature.kind {
+		case "temp":
+			if input, hasInput := feature.get("input"); hasInput {
+				if max, hasMax := chipTempMax[feature.chip]; !hasMax || input.Value > max {
+					chipTempMax[feature.chip] = input.Value
+				}
+
+				if _, isCPU := cpuTempDrivers[chipPrefix(feature.chip)]; isCPU && input.Value > cpuTempMax {
+					cpuTempMax = input.Value
+				}
+			}
+		case "fan":
+			if input, hasInput := feature.get("input"); hasInput {
+				if min, hasMin := chipFanMin[feature.chip]; !hasMin || input.Value < min {
+					chipFanMin[feature.chip] = input.Value
+				}
+			}
+		case "power":
+			if input, hasInput := feature.get("input"); hasInput {
+				powerTotal += input.Value
+				hasPower = true
+			} else if average, hasAverage := feature.get("average"); hasAverage {
+				powerTotal += average.Value
+				hasPower = true
+			}
+		}
+	}
+
+	for _, chip := range chips {
+		if max, hasMax := chipTempMax[chip]; hasMax {
+			aggregates = append(aggregates, Perfdata{
+				Label: pdl(chip, "temp_max"),
+				Value: max,
+				Warn:  *chipTempMaxWarn,
+				Crit:  *chipTempMaxCrit,
+			})
+		}
+
+		if min, hasMin := chipFanMin[chip]; hasMin {
+			aggregates = append(aggregates, Perfdata{
+				Label: pdl(chip, "fan_min"),
+				Value: min,
+				Warn:  *chipFanMinWarn,
+				Crit:  *chipFanMinCrit,
+				Min:   OptionalNumber{true, 0},
+			})
+		}
+	}
+
+	if *chipFilter != "" {
+		return
+	}
+
+	if cpuTempMax != negInf {
+		aggregates = append(aggregates, Perfdata{
+			Label: "cpu_temp_max",
+			Value: cpuTempMax,
+			Warn:  *cpuTempMaxWarn,
+			Crit:  *cpuTempMaxCrit,
+		})
+	}
+
+	if hasPower {
+		aggregates = append(aggregates, Perfdata{
+			Label: "power_total",
+			Value: powerTotal,
+			Warn:  *powerTotalWarn,
+			Crit:  *powerTotalCrit,
+			Min:   OptionalNumber{true, 0},
+		})
+	}
+
+	return
+}
+
+func fmtAggregate(pd Perfdata) string {
+	switch {
+	case strings.HasSuffix(pd.Label, "temp_max"):
+		return fmtNum(pd.Value, kindUnits["temp"])
+	case strings.HasSuffix(pd.Label, "fan_min"):
+		return fmtNum(pd.Value, kindUnits["fan"])
+	default:
+		return fmtNum(pd.Value, kindUnits["power"])
+	}
+}
+
+// chipPrefix returns the driver part of a chip name, e.g. "coretemp" of "coretemp-isa-0000".
+func chipPrefix(chip string) string {
+	if dash := strings.Index(chip, "-"); dash >= 0 {
+		return chip[:dash]
+	}
+
+	return chip
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	. "github.com/Al2Klimov/go-monplug-utils"
+	"math"
+	"strconv"
+	"time"
+)
+
+// baselineConfig tells how to learn the baseline of the inputs of a feature kind and when to alert.
+type baselineConfig struct {
+	alpha     float64
+	warmup    int
+	sigmaWarn float64
+	sigmaCrit float64
+}
+
+var baselineConfigs = map[string]baselineConfig{}
+
+// baseline is the exponentially weighted moving mean and variance of a perfdata.
+type baseline struct {
+	Time     time.Time `json:"time"`
+	Count    int       `json:"count"`
+	Mean     float64   `json:"mean"`
+	Variance float64   `json:"variance"`
+}
+
+// parseBaselineSection interprets a [baseline KIND] section like:
+//
+//	alpha = 0.05
+//	warmup = 20
+//	sigma-warn = 3
+//	sigma-crit = 5
+func parseBaselineSection(section *configSection) error {
+	if errRK := requireInputKind(section); errRK != nil {
+		return errRK
+	}
+
+	bc := baselineConfig{alpha: 0.05, warmup: 20}
+
+	for _, entry := range section.entries {
+		switch entry.key {
+		case "alpha", "sigma-warn", "sigma-crit":
+			value, errPF := strconv.ParseFloat(entry.value, 64)
+			if errPF != nil {
+				return errorAt(entry.line, errPF)
+			}
+
+			if value <= 0 || entry.key == "alpha" && value > 1 {
+				return errorAt(entry.line, errors.New(entry.key+" out of range"))
+			}
+
+			switch entry.key {
+			case "alpha":
+				bc.alpha = value
+			case "sigma-warn":
+				bc.sigmaWarn = value
+			default:
+				bc.sigmaCrit = value
+			}
+		case "warmup":
+			warmup, errPI := strconv.ParseUint(entry.value, 10, 31)
+			if errPI != nil {
+				return errorAt(entry.line, errPI)
+			}
+
+			bc.warmup = int(warmup)
+		default:
+			return errorAt(entry.line, errors.New("unknown key: "+entry.key))
+		}
+	}
+
+	baselineConfigs[section.name] = bc
+	return nil
+}
+
+// detectAnomalies reports how many standard deviations the inputs of the configured kinds
+// deviate from their baselines (once learned) and learns the current inputs.
+// Only the anomalous ones are returned (and described) for the long output.
+func detectAnomalies(sf *stateFile, features []featureReading) (all, anomalous PerfdataCollection, descriptions map[string]string) {
+	if sf.data.Baselines == nil {
+		sf.data.Baselines = map[string]*baseline{}
+	}
+
+	descriptions = map[string]string{}
+
+	for i := range features {
+		feature := &features[i]
+
+		bc, isConfigured := baselineConfigs[feature.kind]
+		if !isConfigured {
+			continue
+		}
+
+		input, hasInput := feature.get("input")
+		if !hasInput {
+			continue
+		}
+
+		bl, hasBaseline := sf.data.Baselines[input.Label]
+		if !hasBaseline {
+			bl = &baseline{Mean: input.Value}
+			sf.data.Baselines[input.Label] = bl
+		}
+
+		if stddev := math.Sqrt(bl.Variance); bl.Count >= bc.warmup && stddev > 0 {
+			pd := Perfdata{Label: input.Label + "_deviation", Value: (input.Value - bl.Mean) / stddev}
+
+			if bc.sigmaWarn > 0 {
+				pd.Warn = OptionalThreshold{true, false, -bc.sigmaWarn, bc.sigmaWarn}
+			}
+
+			if bc.sigmaCrit > 0 {
+				pd.Crit = OptionalThreshold{true, false, -bc.sigmaCrit, bc.sigmaCrit}
+			}
+
+			all = append(all, pd)
+
+			if perfdataState(pd) != stateOk {
+				unit := kindUnits[feature.kind]
+
+				anomalous = append(anomalous, pd)
+				descriptions[pd.Label] = fmt.Sprintf(
+					"%s, %s sigma from the usual %s +/- %s",
+					fmtNum(input.Value, unit), fmtFloat(pd.Value), fmtNum(bl.Mean, unit), fmtNum(stddev, unit),
+				)
+			}
+		}
+
+		diff := input.Value - bl.Mean
+		bl.Mean += bc.alpha * diff
+		bl.Variance = (1 - bc.alpha) * (bl.Variance + bc.alpha*diff*diff)
+		bl.Count++
+		bl.Time = sf.now
+	}
+
+	return
+}
+
+// fmtAnomaly describes an anomalous input given the descriptions by detectAnomalies.
+func fmtAnomaly(descriptions map[string]string) func(Perfdata) string {
+	return func(pd Perfdata) string {
+		return descriptions[pd.Label]
+	}
+}
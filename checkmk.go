@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	. "github.com/Al2Klimov/go-monplug-utils"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var checkmkServicePer = flag.String("checkmk-service-per", "chip", "Print a Checkmk service per chip or feature")
+
+// checkmkMetricName matches what's not allowed in Checkmk metric names.
+var checkmkMetricName = regexp.MustCompile(`\W+`)
+
+// checkmkService is what's printed as a Checkmk local check.
+type checkmkService struct {
+	name     string
+	prefix   string
+	text     string
+	perfdata PerfdataCollection
+}
+
+// printCheckmk prints the readings as Checkmk local checks.
+func printCheckmk() int {
+	if *checkmkServicePer != "chip" && *checkmkServicePer != "feature" {
+		fmt.Fprintln(os.Stderr, "--checkmk-service-per must be chip or feature")
+		return 3
+	}
+
+	_, perfdata, features, errs := readSensors(true)
+	if errs != nil {
+		fmt.Printf("3 \"Linux sensors\" - %s\n", checkmkText(fmtErrs(errs)))
+		return 0
+	}
+
+	services := []*checkmkService{}
+	byPrefix := map[string]*checkmkService{}
+
+	for i := range features {
+		feature := &features[i]
+
+		if *checkmkServicePer == "chip" {
+			prefix := feature.chip + "::"
+
+			service, hasService := byPrefix[prefix]
+			if !hasService {
+				service = &checkmkService{name: "Sensors " + feature.chip, prefix: prefix}
+				services = append(services, service)
+				byPrefix[prefix] = service
+			}
+
+			if problem := featureProblem(feature); problem != "" {
+				service.text += ", " + feature.label + " " + problem
+			}
+		} else {
+			prefix := pdl(feature.chip, feature.name) + "::"
+			service := &checkmkService{name: "Sensor " + feature.chip + " " + feature.label, prefix: prefix}
+
+			if input, hasInput := feature.get("input"); hasInput {
+				service.text = ", " + fmtNum(input.Value, kindUnits[feature.kind])
+			}
+
+			if problem := featureProblem(feature); problem != "" {
+				service.text += ", " + problem
+			}
+
+			services = append(services, service)
+			byPrefix[prefix] = service
+		}
+	}
+
+	rest := &checkmkService{name: "Linux sensors"}
+
+	for _, pd := range perfdata {
+		service := rest
+
+		if *checkmkServicePer == "chip" {
+			if sep := strings.Index(pd.Label, "::"); sep >= 0 {
+				if s, hasService := byPrefix[pd.Label[:sep+2]]; hasService {
+					service = s
+				}
+			}
+		} else if s, hasService := byPrefix[featureOf(pd.Label)+"::"]; hasService {
+			service = s
+		}
+
+		service.perfdata = append(service.perfdata, pd)
+	}
+
+	for _, service := range append(services, rest) {
+		fmt.Println(service.String())
+	}
+
+	return 0
+}
+
+// featureProblem describes what's wrong with a feature if anything.
+func featureProblem(feature *featureReading) string {
+	switch {
+	case feature.hasFault:
+		return "FAULT"
+	case feature.hasAlarm:
+		return "ALARM"
+	case feature.isImplausible:
+		return "IMPLAUSIBLE"
+	default:
+		return ""
+	}
+}
+
+// String renders a local check line, letting Checkmk compute the state ("P") if all thresholds allow it.
+func (cs *checkmkService) String() string {
+	metrics := []string{}
+	state := stateOk
+	computable := true
+
+	for _, pd := range cs.perfdata {
+		metric := bytes.Buffer{}
+
+		metric.Write([]byte(checkmkMetricName.ReplaceAllString(strings.TrimPrefix(pd.Label, cs.prefix), "_")))
+		metric.Write([]byte{'='})
+		metric.Write([]byte(strconv.FormatFloat(pd.Value, 'f', -1, 64)))
+
+		for _, threshold := range [2]OptionalThreshold{pd.Warn, pd.Crit} {
+			levels, isExpressible := checkmkLevels(threshold)
+			computable = computable && isExpressible
+
+			metric.Write([]byte{';'})
+			metric.Write([]byte(levels))
+		}
+
+		for _, number := range [2]OptionalNumber{pd.Min, pd.Max} {
+			metric.Write([]byte{';'})
+
+			if number.IsSet {
+				metric.Write([]byte(strconv.FormatFloat(number.Value, 'f', -1, 64)))
+			}
+		}
+
+		metrics = append(metrics, strings.TrimRight(metric.String(), ";"))
+
+		if pdState := perfdataState(pd); pdState > state {
+			state = pdState
+		}
+	}
+
+	stateField := "P"
+	if !computable {
+		stateField = strconv.Itoa(state)
+	}
+
+	metricsField := "-"
+	if len(metrics) > 0 {
+		metricsField = strings.Join(metrics, "|")
+	}
+
+	text := strings.TrimPrefix(cs.text, ", ")
+	if text == "" {
+		text = "OK"
+	}
+
+	return fmt.Sprintf("%s %s %s %s", stateField, strconv.Quote(cs.name), metricsField, checkmkText(text))
+}
+
+// checkmkLevels converts a threshold to Checkmk's "upper" or "lower:upper" levels if possible.
+func checkmkLevels(threshold OptionalThreshold) (levels string, isExpressible bool) {
+	if !threshold.IsSet {
+		return "", true
+	}
+
+	if threshold.Inverted || threshold.End == posInf {
+		return "", false
+	}
+
+	end := strconv.FormatFloat(threshold.End, 'f', -1, 64)
+	if threshold.Start == negInf {
+		return end, true
+	}
+
+	return strconv.FormatFloat(threshold.Start, 'f', -1, 64) + ":" + end, true
+}
+
+// checkmkText makes a text fit into a single local check line.
+func checkmkText(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// fmtErrs joins errors like printErrs prints them.
+func fmtErrs(errs map[string]error) string {
+	msgs := make([]string, 0, len(errs))
+
+	for context, err := range errs {
+		msgs = append(msgs, context+": "+err.Error())
+	}
+
+	return strings.Join(msgs, "; ")
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// collectdTypes map the kinds of features to the types of collectd's types.db used by collectd's sensors plugin.
+var collectdTypes = map[string]string{
+	"in":       "voltage",
+	"fan":      "fanspeed",
+	"temp":     "temperature",
+	"curr":     "current",
+	"power":    "power",
+	"energy":   "energy",
+	"humidity": "humidity",
+}
+
+// collectdExec runs forever speaking collectd's exec plugin protocol.
+func collectdExec(args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "collectd-exec takes no arguments")
+		return 3
+	}
+
+	host := os.Getenv("COLLECTD_HOSTNAME")
+	if host == "" {
+		hostname, errHn := os.Hostname()
+		if errHn != nil {
+			printErrs(map[string]error{"os.Hostname()": errHn})
+			return 3
+		}
+
+		host = hostname
+	}
+
+	interval := 10 * time.Second
+
+	if rawInterval := os.Getenv("COLLECTD_INTERVAL"); rawInterval != "" {
+		seconds, errPF := strconv.ParseFloat(rawInterval, 64)
+		if errPF != nil || seconds <= 0 {
+			fmt.Fprintf(os.Stderr, "bad COLLECTD_INTERVAL: %s\n", rawInterval)
+			return 3
+		}
+
+		interval = time.Duration(seconds * float64(time.Second))
+	}
+
+	for {
+		start := time.Now()
+
+		_, _, features, errs := readSensors(false)
+		if errs == nil {
+			for i := range features {
+				feature := &features[i]
+
+				typ, isCollectable := collectdTypes[feature.kind]
+				if !isCollectable {
+					continue
+				}
+
+				if input, hasInput := feature.get("input"); hasInput {
+					fmt.Printf(
+						"PUTVAL \"%s/sensors-%s/%s-%s\" interval=%s %d:%s\n",
+						host, feature.chip, typ, feature.name, strconv.FormatFloat(interval.Seconds(), 'f', -1, 64),
+						start.Unix(), strconv.FormatFloat(input.Value, 'f', -1, 64),
+					)
+				}
+			}
+		} else {
+			printErrs(errs)
+		}
+
+		time.Sleep(interval - time.Since(start))
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var configFile = flag.String("config", "", "Read virtual sensors, rules, fan groups etc. from this file")
+
+// configEntry is a "key = value" line of the configuration file.
+type configEntry struct {
+	key   string
+	value string
+	line  int
+}
+
+// configSection is a "[kind name]" block of the configuration file.
+type configSection struct {
+	kind    string
+	name    string
+	line    int
+	entries []configEntry
+}
+
+// configSectionParsers interpret the sections of the configuration file by kind.
+var configSectionParsers = map[string]func(section *configSection) error{
+	"virtual":       parseVirtualSection,
+	"rule":          parseRuleSection,
+	"fan-group":     parseFanGroupSection,
+	"rate":          parseRateSection,
+	"power-derived": parsePowerDerivedSection,
+	"stale":         parseStaleSection,
+	"plausible":     parsePlausibleSection,
+	"hysteresis":    parseHysteresisSection,
+	"baseline":      parseBaselineSection,
+	"forecast":      parseForecastSection,
+}
+
+// loadConfig reads and interprets the configuration file (if any).
+func loadConfig() error {
+	if *configFile == "" {
+		return nil
+	}
+
+	sections, errRC := readConfig(*configFile)
+	if errRC != nil {
+		return errRC
+	}
+
+	for i := range sections {
+		section := &sections[i]
+
+		parser, hasParser := configSectionParsers[section.kind]
+		if !hasParser {
+			return fmt.Errorf("%s:%d: unknown section [%s]", *configFile, section.line, section.kind)
+		}
+
+		if errPS := parser(section); errPS != nil {
+			return fmt.Errorf("%s:%s", *configFile, errPS.Error())
+		}
+	}
+
+	return nil
+}
+
+// readConfig parses an INI-like file with "[kind name]" section headers, "key = value" lines and "#" comments.
+func readConfig(path string) (sections []configSection, err error) {
+	file, errOp := os.Open(path)
+	if errOp != nil {
+		return nil, errOp
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	line := 0
+
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case text == "" || strings.HasPrefix(text, "#"):
+		case strings.HasPrefix(text, "["):
+			if !strings.HasSuffix(text, "]") {
+				return nil, fmt.Errorf("%s:%d: unterminated section header", path, line)
+			}
+
+			header := strings.Fields(text[1 : len(text)-1])
+			if len(header) < 1 || len(header) > 2 {
+				return nil, fmt.Errorf("%s:%d: bad section header", path, line)
+			}
+
+			section := configSection{kind: header[0], line: line}
+			if len(header) > 1 {
+				section.name = header[1]
+			}
+
+			sections = append(sections, section)
+		default:
+			equals := strings.Index(text, "=")
+			if equals < 0 {
+				return nil, fmt.Errorf("%s:%d: expected key = value", path, line)
+			}
+
+			if len(sections) < 1 {
+				return nil, fmt.Errorf("%s:%d: key outside of any section", path, line)
+			}
+
+			section := &sections[len(sections)-1]
+			section.entries = append(section.entries, configEntry{
+				key:   strings.TrimSpace(text[:equals]),
+				value: strings.TrimSpace(text[equals+1:]),
+				line:  line,
+			})
+		}
+	}
+
+	if errSc := scanner.Err(); errSc != nil {
+		return nil, errSc
+	}
+
+	return
+}
+
+// errorAt prefixes an error with the line it refers to.
+func errorAt(line int, err error) error {
+	return fmt.Errorf("%d: %s", line, err.Error())
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	sensors "github.com/Al2Klimov/go-linux-sensors"
+	"os"
+	"path"
+	"strconv"
+)
+
+var chipFilter = flag.String("chip", "", "Only read the chips matching this glob (e.g. coretemp-*)")
+
+// discoveredChip is a chip as listed by discover.
+type discoveredChip struct {
+	Name     string              `json:"name"`
+	Adapter  string              `json:"adapter,omitempty"`
+	Features []discoveredFeature `json:"features"`
+}
+
+// discoveredFeature is a feature as listed by discover.
+type discoveredFeature struct {
+	Name  string `json:"name"`
+	Label string `json:"label"`
+	Type  string `json:"type"`
+}
+
+// filterChips returns the chips matching --chip.
+func filterChips(chips []*sensors.ChipName) ([]*sensors.ChipName, map[string]error) {
+	if *chipFilter == "" {
+		return chips, nil
+	}
+
+	if _, errMt := path.Match(*chipFilter, ""); errMt != nil {
+		return nil, map[string]error{"--chip": errMt}
+	}
+
+	filtered := []*sensors.ChipName{}
+
+	for _, chip := range chips {
+		chipName, errMB := chip.MarshalBinary()
+		if errMB != nil {
+			return nil, map[string]error{"sensors_snprintf_chip_name()": errMB}
+		}
+
+		if chipIsSelected(string(chipName)) {
+			filtered = append(filtered, chip)
+		}
+	}
+
+	return filtered, nil
+}
+
+// chipIsSelected tells whether a chip matches --chip.
+func chipIsSelected(chip string) bool {
+	if *chipFilter == "" {
+		return true
+	}
+
+	match, _ := path.Match(*chipFilter, chip)
+	return match
+}
+
+// discoverChips lists the (matching) chips and their supported features without reading them.
+func discoverChips() (chips []discoveredChip, errs map[string]error) {
+	sensors.Init(nil)
+	defer sensors.Cleanup()
+
+	detected, errsFC := filterChips(sensors.GetDetectedChips(nil))
+	if errsFC != nil {
+		return nil, errsFC
+	}
+
+	chips = []discoveredChip{}
+
+	for _, chip := range detected {
+		chipName, errMB := chip.MarshalBinary()
+		if errMB != nil {
+			return nil, map[string]error{"sensors_snprintf_chip_name()": errMB}
+		}
+
+		adapterName, _ := chip.GetBus().GetAdapterName()
+		dc := discoveredChip{Name: string(chipName), Adapter: adapterName, Features: []discoveredFeature{}}
+
+		for _, feature := range chip.GetFeatures() {
+			kind := featureKind(feature)
+			if kind == "" {
+				continue
+			}
+
+			featureName := feature.GetName()
+
+			label, hasLabel := chip.GetLabel(feature)
+			if !hasLabel {
+				label = featureName
+			}
+
+			dc.Features = append(dc.Features, discoveredFeature{Name: featureName, Label: label, Type: kind})
+		}
+
+		chips = append(chips, dc)
+	}
+
+	return
+}
+
+// discover lists the chips and features as JSON (default) or as Icinga 2 host custom variables.
+func discover(args []string) int {
+	format := "json"
+
+	switch len(args) {
+	case 0:
+	case 1:
+		format = args[0]
+	default:
+		fmt.Fprintln(os.Stderr, "usage: discover [json|icinga2]")
+		return 3
+	}
+
+	if format != "json" && format != "icinga2" {
+		fmt.Fprintf(os.Stderr, "unknown format: %s\n", format)
+		return 3
+	}
+
+	chips, errs := discoverChips()
+	if errs != nil {
+		printErrs(errs)
+		return 3
+	}
+
+	if format == "json" {
+		content, errMs := json.MarshalIndent(chips, "", "\t")
+		if errMs != nil {
+			printErrs(map[string]error{"json.Marshal()": errMs})
+			return 3
+		}
+
+		fmt.Println(string(content))
+		return 0
+	}
+
+	fmt.Print(icinga2ChipVars(chips))
+	return 0
+}
+
+// icinga2ChipVars renders the host custom variables the per chip services are applied for.
+func icinga2ChipVars(chips []discoveredChip) string {
+	buf := bytes.Buffer{}
+
+	buf.Write([]byte("vars.linux_sensors_chips = [\n"))
+
+	for _, chip := range chips {
+		fmt.Fprintf(&buf, "\t%s,\n", strconv.Quote(chip.Name))
+	}
+
+	buf.Write([]byte("]\n\nvars.linux_sensors_features = {\n"))
+
+	for _, chip := range chips {
+		fmt.Fprintf(&buf, "\t%s = [\n", strconv.Quote(chip.Name))
+
+		for _, feature := range chip.Features {
+			fmt.Fprintf(&buf, "\t\t%s,\n", strconv.Quote(feature.Name))
+		}
+
+		buf.Write([]byte("\t]\n"))
+	}
+
+	buf.Write([]byte("}\n"))
+
+	return buf.String()
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	. "github.com/Al2Klimov/go-monplug-utils"
+	"path"
+	"strconv"
+)
+
+var powerDerivedWarn, powerDerivedCrit OptionalThreshold
+
+// energyWrap is the value (in J) energy counters wrap around at or 0 if unknown.
+var energyWrap float64
+
+// energyFeatureWraps override energyWrap for "chip::feature" globs.
+var energyFeatureWraps []struct {
+	glob string
+	wrap float64
+}
+
+// parsePowerDerivedSection interprets a [power-derived] section like:
+//
+//	warn = 150
+//	crit = 200
+//	wrap = 4294.967296
+//
+// or a [power-derived CHIP::FEATURE-GLOB] section setting just the wrap value of specific counters.
+func parsePowerDerivedSection(section *configSection) error {
+	if section.name != "" {
+		return parsePowerDerivedFeatureSection(section)
+	}
+
+	for _, entry := range section.entries {
+		switch entry.key {
+		case "warn", "crit":
+			threshold, errPT := parseThreshold(entry.value)
+			if errPT != nil {
+				return errorAt(entry.line, errPT)
+			}
+
+			if entry.key == "warn" {
+				powerDerivedWarn = threshold
+			} else {
+				powerDerivedCrit = threshold
+			}
+		case "wrap":
+			wrap, errPW := parseWrap(entry)
+			if errPW != nil {
+				return errPW
+			}
+
+			energyWrap = wrap
+		default:
+			return errorAt(entry.line, errors.New("unknown key: "+entry.key))
+		}
+	}
+
+	return nil
+}
+
+func parsePowerDerivedFeatureSection(section *configSection) error {
+	if _, errMt := path.Match(section.name, ""); errMt != nil {
+		return errorAt(section.line, errMt)
+	}
+
+	wrap := 0.0
+
+	for _, entry := range section.entries {
+		switch entry.key {
+		case "wrap":
+			value, errPW := parseWrap(entry)
+			if errPW != nil {
+				return errPW
+			}
+
+			wrap = value
+		default:
+			return errorAt(entry.line, errors.New("unknown key: "+entry.key))
+		}
+	}
+
+	if wrap == 0 {
+		return errorAt(section.line, errors.New("[power-derived CHIP::FEATURE-GLOB] requires wrap"))
+	}
+
+	energyFeatureWraps = append(energyFeatureWraps, struct {
+		glob string
+		wrap float64
+	}{section.name, wrap})
+
+	return nil
+}
+
+func parseWrap(entry configEntry) (float64, error) {
+	wrap, errPF := strconv.ParseFloat(entry.value, 64)
+	if errPF != nil {
+		return 0, errorAt(entry.line, errPF)
+	}
+
+	if wrap <= 0 {
+		return 0, errorAt(entry.line, errors.New("wrap must be positive"))
+	}
+
+	return wrap, nil
+}
+
+// energyWrapOf returns the value (in J) the energy counter of a feature wraps around at or 0 if unknown.
+func energyWrapOf(chip, feature string) float64 {
+	for _, fw := range energyFeatureWraps {
+		if matches, _ := path.Match(fw.glob, pdl(chip, feature)); matches {
+			return fw.wrap
+		}
+	}
+
+	return energyWrap
+}
+
+// derivePower computes the average power between the previous and the current energy readings.
+//
+// A decreasing counter has either wrapped around or been reset (e.g. by a driver reload).
+// It's considered wrapped only if the wrap value is configured and the counter
+// has advanced by less than half of it, i.e. the previous value was close to the wrap value.
+// As well as after a reboot, nothing is reported after a reset until the next run.
+func derivePower(sf *stateFile, features []featureReading) (powers PerfdataCollection) {
+	if sf.rebooted {
+		return
+	}
+
+	for i := range features {
+		feature := &features[i]
+		if feature.kind != "energy" {
+			continue
+		}
+
+		input, hasInput := feature.get("input")
+		if !hasInput {
+			continue
+		}
+
+		last, hasLast := sf.last(input.Label)
+		if !hasLast {
+			continue
+		}
+
+		delta := input.Value - last.Value
+		if wrap := energyWrapOf(feature.chip, feature.name); delta < 0 && wrap > 0 && delta+wrap < wrap/2 {
+			delta += wrap
+		}
+
+		if delta < 0 {
+			continue
+		}
+
+		powers = append(powers, Perfdata{
+			Label: pdl(feature.chip, feature.name, "power_derived"),
+			Value: delta / sf.now.Sub(last.Time).Seconds(),
+			Warn:  powerDerivedWarn,
+			Crit:  powerDerivedCrit,
+			Min:   OptionalNumber{true, 0},
+		})
+	}
+
+	return
+}
+
+func fmtPowerDerived(pd Perfdata) string {
+	return fmtNum(pd.Value, kindUnits["power"])
+}
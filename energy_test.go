@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/Al2Klimov/go-monplug-utils"
+)
+
+func TestDerivePower(t *testing.T) {
+	defer func(wrap float64) { energyWrap = wrap }(energyWrap)
+	defer func() { energyFeatureWraps = nil }()
+
+	energyWrap = 1000
+	energyFeatureWraps = append(energyFeatureWraps, struct {
+		glob string
+		wrap float64
+	}{"small-*::energy1", 100})
+
+	now := time.Now()
+
+	for _, tc := range []struct {
+		chip     string
+		last     float64
+		current  float64
+		expected []float64
+	}{
+		{"big-isa-0000", 100, 700, []float64{10}},
+		{"big-isa-0000", 940, 40, []float64{1.6666666666666667}},
+		{"big-isa-0000", 400, 100, nil},
+		{"small-isa-0000", 94, 4, []float64{0.16666666666666666}},
+		{"small-isa-0000", 40, 10, nil},
+	} {
+		label := pdl(tc.chip, "energy1", "input")
+		sf := &stateFile{now: now, data: pluginState{Labels: map[string]*labelState{
+			label: {Time: now.Add(-time.Minute), Value: tc.last},
+		}}}
+
+		features := []featureReading{{
+			chip:     tc.chip,
+			name:     "energy1",
+			kind:     "energy",
+			perfdata: PerfdataCollection{{Label: label, Value: tc.current}},
+		}}
+
+		powers := derivePower(sf, features)
+		if len(powers) != len(tc.expected) {
+			t.Errorf("%s: %v -> %v: got %d powers, expected %d", tc.chip, tc.last, tc.current, len(powers), len(tc.expected))
+			continue
+		}
+
+		for i, power := range powers {
+			if power.Value != tc.expected[i] {
+				t.Errorf("%s: %v -> %v: got %v W, expected %v W", tc.chip, tc.last, tc.current, power.Value, tc.expected[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,560 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	. "github.com/Al2Klimov/go-monplug-utils"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// exprNode is a parsed expression over the perfdata collected so far.
+//
+// Bare identifiers (e.g. power1) refer to a perfdata label
+// or - if there's no such one - to the input of the only feature with that name.
+// Quoted strings (e.g. "coretemp-*::temp*::input") are perfdata label globs.
+// Inside function calls (min, max, sum, avg, count) they may match any number of labels,
+// elsewhere exactly one.
+// Comparisons and the logical operators (and, or, not) yield 1 for true and 0 for false.
+type exprNode interface {
+	eval(env *exprEnv) (float64, error)
+}
+
+// exprEnv holds the values expressions are evaluated over.
+type exprEnv struct {
+	labels      []string
+	values      map[string]float64
+	implausible map[string]struct{}
+}
+
+// unavailableSensorError tells that an expression refers to a sensor which hasn't been read (plausibly).
+type unavailableSensorError struct {
+	reason string
+	name   string
+}
+
+func (use *unavailableSensorError) Error() string {
+	return use.reason + ": " + use.name
+}
+
+// newExprEnv makes the perfdata available to expressions, except for implausible inputs.
+func newExprEnv(perfdata PerfdataCollection) *exprEnv {
+	env := &exprEnv{values: map[string]float64{}, implausible: map[string]struct{}{}}
+
+	for _, pd := range perfdata {
+		if strings.HasSuffix(pd.Label, "::input_implausible") {
+			env.implausible[strings.TrimSuffix(pd.Label, "_implausible")] = struct{}{}
+		}
+	}
+
+	for _, pd := range perfdata {
+		if _, isImplausible := env.implausible[pd.Label]; !isImplausible {
+			env.set(pd.Label, pd.Value)
+		}
+	}
+
+	return env
+}
+
+func (env *exprEnv) set(label string, value float64) {
+	if _, hasLabel := env.values[label]; !hasLabel {
+		env.labels = append(env.labels, label)
+	}
+
+	env.values[label] = value
+}
+
+func (env *exprEnv) glob(pattern string) ([]float64, error) {
+	values := []float64{}
+
+	for _, label := range env.labels {
+		matches, errMt := path.Match(pattern, label)
+		if errMt != nil {
+			return nil, errMt
+		}
+
+		if matches {
+			values = append(values, env.values[label])
+		}
+	}
+
+	return values, nil
+}
+
+func (env *exprEnv) lookup(name string, quoted bool) (float64, error) {
+	if value, hasValue := env.values[name]; hasValue {
+		return value, nil
+	}
+
+	if _, isImplausible := env.implausible[name]; isImplausible {
+		return 0, &unavailableSensorError{"implausible sensor", name}
+	}
+
+	pattern := name
+	if !quoted {
+		pattern = pdl("*", name, "input")
+	}
+
+	values, errGl := env.glob(pattern)
+	if errGl != nil {
+		return 0, errGl
+	}
+
+	switch len(values) {
+	case 0:
+		return 0, env.unavailable(name, pattern)
+	case 1:
+		return values[0], nil
+	default:
+		return 0, errors.New("ambiguous sensor: " + name)
+	}
+}
+
+// unavailable explains why the sensor name (resolved to the label glob pattern) matches nothing.
+func (env *exprEnv) unavailable(name, pattern string) error {
+	for label := range env.implausible {
+		if match, _ := path.Match(pattern, label); match {
+			return &unavailableSensorError{"implausible sensor", name}
+		}
+	}
+
+	return &unavailableSensorError{"no such sensor", name}
+}
+
+type exprNumber float64
+
+func (en exprNumber) eval(*exprEnv) (float64, error) {
+	return float64(en), nil
+}
+
+type exprRef struct {
+	name   string
+	quoted bool
+}
+
+func (er *exprRef) eval(env *exprEnv) (float64, error) {
+	return env.lookup(er.name, er.quoted)
+}
+
+type exprUnary struct {
+	op      string
+	operand exprNode
+}
+
+func (eu *exprUnary) eval(env *exprEnv) (float64, error) {
+	operand, errEv := eu.operand.eval(env)
+	if errEv != nil {
+		return 0, errEv
+	}
+
+	return exprUnaryOps[eu.op].apply(operand), nil
+}
+
+type exprBinary struct {
+	op          string
+	left, right exprNode
+}
+
+func (eb *exprBinary) eval(env *exprEnv) (float64, error) {
+	left, errLeft := eb.left.eval(env)
+	if errLeft != nil {
+		return 0, errLeft
+	}
+
+	right, errRight := eb.right.eval(env)
+	if errRight != nil {
+		return 0, errRight
+	}
+
+	return exprBinaryOps[eb.op].apply(left, right)
+}
+
+type exprCall struct {
+	function string
+	args     []exprNode
+}
+
+func (ec *exprCall) eval(env *exprEnv) (float64, error) {
+	values := []float64{}
+
+	for _, arg := range ec.args {
+		if ref, isRef := arg.(*exprRef); isRef && ref.quoted {
+			matches, errGl := env.glob(ref.name)
+			if errGl != nil {
+				return 0, errGl
+			}
+
+			// Counting nothing is fine, everything else over nothing doesn't make sense.
+			if len(matches) < 1 && ec.function != "count" {
+				return 0, env.unavailable(ref.name, ref.name)
+			}
+
+			values = append(values, matches...)
+		} else {
+			value, errEv := arg.eval(env)
+			if errEv != nil {
+				return 0, errEv
+			}
+
+			values = append(values, value)
+		}
+	}
+
+	return exprFunctions[ec.function](values)
+}
+
+// exprUnaryOp is a prefix operator and the precedence of its operand,
+// e.g. not binds looser than the comparisons, so `not a > b` means `not (a > b)`.
+type exprUnaryOp struct {
+	precedence int
+	apply      func(x float64) float64
+}
+
+var exprUnaryOps = map[string]exprUnaryOp{
+	"-":   {6, func(x float64) float64 { return -x }},
+	"not": {2, func(x float64) float64 { return exprBool(x == 0) }},
+}
+
+// exprBinaryOp is a binary operator and its precedence (higher binds tighter).
+type exprBinaryOp struct {
+	precedence int
+	apply      func(x, y float64) (float64, error)
+}
+
+var exprBinaryOps = map[string]exprBinaryOp{
+	"or":  {1, func(x, y float64) (float64, error) { return exprBool(x != 0 || y != 0), nil }},
+	"and": {2, func(x, y float64) (float64, error) { return exprBool(x != 0 && y != 0), nil }},
+	"<":   {3, func(x, y float64) (float64, error) { return exprBool(x < y), nil }},
+	"<=":  {3, func(x, y float64) (float64, error) { return exprBool(x <= y), nil }},
+	">":   {3, func(x, y float64) (float64, error) { return exprBool(x > y), nil }},
+	">=":  {3, func(x, y float64) (float64, error) { return exprBool(x >= y), nil }},
+	"==":  {3, func(x, y float64) (float64, error) { return exprBool(x == y), nil }},
+	"!=":  {3, func(x, y float64) (float64, error) { return exprBool(x != y), nil }},
+	"+":   {4, func(x, y float64) (float64, error) { return x + y, nil }},
+	"-":   {4, func(x, y float64) (float64, error) { return x - y, nil }},
+	"*":   {5, func(x, y float64) (float64, error) { return x * y, nil }},
+	"/": {5, func(x, y float64) (float64, error) {
+		if y == 0 {
+			return 0, errors.New("division by zero")
+		}
+
+		return x / y, nil
+	}},
+}
+
+func exprBool(b bool) float64 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+var exprFunctions = map[string]func(values []float64) (float64, error){
+	"abs": func(values []float64) (float64, error) {
+		if len(values) != 1 {
+			return 0, errors.New("abs() takes exactly one value")
+		}
+
+		return math.Abs(values[0]), nil
+	},
+	"min": func(values []float64) (float64, error) {
+		if len(values) < 1 {
+			return 0, errors.New("min() of no values")
+		}
+
+		min := values[0]
+		for _, value := range values[1:] {
+			min = math.Min(min, value)
+		}
+
+		return min, nil
+	},
+	"max": func(values []float64) (float64, error) {
+		if len(values) < 1 {
+			return 0, errors.New("max() of no values")
+		}
+
+		max := values[0]
+		for _, value := range values[1:] {
+			max = math.Max(max, value)
+		}
+
+		return max, nil
+	},
+	"sum": func(values []float64) (float64, error) {
+		sum := 0.0
+		for _, value := range values {
+			sum += value
+		}
+
+		return sum, nil
+	},
+	"avg": func(values []float64) (float64, error) {
+		if len(values) < 1 {
+			return 0, errors.New("avg() of no values")
+		}
+
+		sum := 0.0
+		for _, value := range values {
+			sum += value
+		}
+
+		return sum / float64(len(values)), nil
+	},
+	"count": func(values []float64) (float64, error) {
+		return float64(len(values)), nil
+	},
+}
+
+const (
+	tokenEOF = iota
+	tokenNumber
+	tokenIdent
+	tokenString
+	tokenOp
+)
+
+type exprToken struct {
+	kind int
+	text string
+	pos  int
+}
+
+// exprOps are the operator tokens, the longer ones first.
+var exprOps = []string{"<=", ">=", "==", "!=", "<", ">", "(", ")", ",", "+", "-", "*", "/"}
+
+func lexExpr(source string) ([]exprToken, error) {
+	tokens := []exprToken{}
+	pos := 0
+
+Tokens:
+	for pos < len(source) {
+		c := source[pos]
+
+		switch {
+		case c == ' ' || c == '\t':
+			pos++
+		case c >= '0' && c <= '9' || c == '.':
+			start := pos
+			for pos < len(source) && (source[pos] >= '0' && source[pos] <= '9' || source[pos] == '.') {
+				pos++
+			}
+
+			if pos < len(source) && (source[pos] == 'e' || source[pos] == 'E') {
+				pos++
+				if pos < len(source) && (source[pos] == '+' || source[pos] == '-') {
+					pos++
+				}
+
+				for pos < len(source) && source[pos] >= '0' && source[pos] <= '9' {
+					pos++
+				}
+			}
+
+			tokens = append(tokens, exprToken{tokenNumber, source[start:pos], start})
+		case c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z':
+			start := pos
+			for pos < len(source) && isIdentChar(source[pos]) {
+				pos++
+			}
+
+			tokens = append(tokens, exprToken{tokenIdent, source[start:pos], start})
+		case c == '"':
+			end := strings.IndexByte(source[pos+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("column %d: unterminated string", pos+1)
+			}
+
+			tokens = append(tokens, exprToken{tokenString, source[pos+1 : pos+1+end], pos})
+			pos += end + 2
+		default:
+			for _, op := range exprOps {
+				if strings.HasPrefix(source[pos:], op) {
+					tokens = append(tokens, exprToken{tokenOp, op, pos})
+					pos += len(op)
+					continue Tokens
+				}
+			}
+
+			return nil, fmt.Errorf("column %d: unexpected %q", pos+1, c)
+		}
+	}
+
+	return append(tokens, exprToken{tokenEOF, "", pos}), nil
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9'
+}
+
+type exprParser struct {
+	tokens []exprToken
+	next   int
+}
+
+// parseExpr compiles an expression like `(in1 - 12) / 12`.
+func parseExpr(source string) (exprNode, error) {
+	tokens, errLx := lexExpr(source)
+	if errLx != nil {
+		return nil, errLx
+	}
+
+	parser := &exprParser{tokens: tokens}
+
+	node, errPB := parser.parseBinary(0)
+	if errPB != nil {
+		return nil, errPB
+	}
+
+	if token := parser.peek(); token.kind != tokenEOF {
+		return nil, parser.unexpected(token)
+	}
+
+	return node, nil
+}
+
+func (ep *exprParser) peek() exprToken {
+	return ep.tokens[ep.next]
+}
+
+func (ep *exprParser) consume() exprToken {
+	token := ep.tokens[ep.next]
+	if token.kind != tokenEOF {
+		ep.next++
+	}
+
+	return token
+}
+
+func (ep *exprParser) unexpected(token exprToken) error {
+	if token.kind == tokenEOF {
+		return errors.New("unexpected end of expression")
+	}
+
+	return fmt.Errorf("column %d: unexpected %q", token.pos+1, token.text)
+}
+
+func (ep *exprParser) expect(op string) error {
+	if token := ep.consume(); token.kind != tokenOp || token.text != op {
+		return ep.unexpected(token)
+	}
+
+	return nil
+}
+
+// parseBinary parses operands joined by binary operators binding tighter than minPrecedence.
+func (ep *exprParser) parseBinary(minPrecedence int) (exprNode, error) {
+	left, errPU := ep.parseUnary(minPrecedence)
+	if errPU != nil {
+		return nil, errPU
+	}
+
+	for {
+		token := ep.peek()
+		if token.kind != tokenOp && token.kind != tokenIdent {
+			return left, nil
+		}
+
+		op, isOp := exprBinaryOps[token.text]
+		if !isOp || op.precedence <= minPrecedence {
+			return left, nil
+		}
+
+		ep.consume()
+
+		right, errPB := ep.parseBinary(op.precedence)
+		if errPB != nil {
+			return nil, errPB
+		}
+
+		left = &exprBinary{token.text, left, right}
+	}
+}
+
+// parseUnary parses an operand, possibly prefixed by unary operators.
+// The operand of such one doesn't extend over binary operators binding looser than minPrecedence.
+func (ep *exprParser) parseUnary(minPrecedence int) (exprNode, error) {
+	if token := ep.peek(); token.kind == tokenOp || token.kind == tokenIdent {
+		if op, isOp := exprUnaryOps[token.text]; isOp {
+			ep.consume()
+
+			if op.precedence > minPrecedence {
+				minPrecedence = op.precedence
+			}
+
+			operand, errPB := ep.parseBinary(minPrecedence)
+			if errPB != nil {
+				return nil, errPB
+			}
+
+			return &exprUnary{token.text, operand}, nil
+		}
+	}
+
+	return ep.parsePrimary()
+}
+
+func (ep *exprParser) parsePrimary() (exprNode, error) {
+	token := ep.consume()
+
+	switch token.kind {
+	case tokenNumber:
+		value, errPF := strconv.ParseFloat(token.text, 64)
+		if errPF != nil {
+			return nil, fmt.Errorf("column %d: bad number %q", token.pos+1, token.text)
+		}
+
+		return exprNumber(value), nil
+	case tokenString:
+		return &exprRef{token.text, true}, nil
+	case tokenIdent:
+		if next := ep.peek(); next.kind == tokenOp && next.text == "(" {
+			if _, isFunction := exprFunctions[token.text]; !isFunction {
+				return nil, fmt.Errorf("column %d: unknown function %s()", token.pos+1, token.text)
+			}
+
+			ep.consume()
+			call := &exprCall{function: token.text}
+
+			if next := ep.peek(); next.kind == tokenOp && next.text == ")" {
+				ep.consume()
+				return call, nil
+			}
+
+			for {
+				arg, errPB := ep.parseBinary(0)
+				if errPB != nil {
+					return nil, errPB
+				}
+
+				call.args = append(call.args, arg)
+
+				if next := ep.consume(); next.kind != tokenOp || next.text != "," && next.text != ")" {
+					return nil, ep.unexpected(next)
+				} else if next.text == ")" {
+					return call, nil
+				}
+			}
+		}
+
+		return &exprRef{token.text, false}, nil
+	case tokenOp:
+		if token.text == "(" {
+			node, errPB := ep.parseBinary(0)
+			if errPB != nil {
+				return nil, errPB
+			}
+
+			if errEx := ep.expect(")"); errEx != nil {
+				return nil, errEx
+			}
+
+			return node, nil
+		}
+	}
+
+	return nil, ep.unexpected(token)
+}
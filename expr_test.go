@@ -0,0 +1,151 @@
+package main
+
+import (
+	"testing"
+
+	. "github.com/Al2Klimov/go-monplug-utils"
+)
+
+// testExprPerfdata is what the expressions in the tests are evaluated over.
+var testExprPerfdata = PerfdataCollection{
+	{Label: "coretemp-isa-0000::temp1::input", Value: 40},
+	{Label: "coretemp-isa-0000::temp2::input", Value: 50},
+	{Label: "nct6775-isa-0290::fan1::input", Value: 1000},
+	{Label: "nct6775-isa-0290::fan2::input", Value: 0},
+	{Label: "nct6775-isa-0290::temp1::input", Value: 30},
+	{Label: "nct6775-isa-0290::temp3::input", Value: 255},
+	{Label: "nct6775-isa-0290::temp3::input_implausible", Value: 1},
+	{Label: "it87-isa-0a30::fan1::input", Value: 65535},
+	{Label: "it87-isa-0a30::fan1::input_implausible", Value: 1},
+	{Label: "power_total", Value: 100},
+}
+
+func TestExprEval(t *testing.T) {
+	for _, tc := range []struct {
+		source   string
+		expected float64
+	}{
+		{"42", 42},
+		{"1e3", 1000},
+		{".5", 0.5},
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 - 4 - 3", 3},
+		{"8 / 4 / 2", 1},
+		{"-2 * 3", -6},
+		{"- -2", 2},
+		{"2 - -2", 4},
+		{"-(1 + 2)", -3},
+		{"not 0", 1},
+		{"not 2", 0},
+		{"not not 2", 1},
+		{"not 1 > 2", 1},
+		{"not 2 > 1", 0},
+		{"not 1 + 1", 0},
+		{"not 0 and 0", 0},
+		{"not 1 or 1", 1},
+		{"1 + not 0", 2},
+		{"not temp2 > 45", 0},
+		{"not -1", 0},
+		{"-not 0", -1},
+		{"1 + 1 == 2", 1},
+		{"1 + 1 != 2", 0},
+		{"1 < 2 and 3 < 2", 0},
+		{"1 < 2 or 3 < 2", 1},
+		{"0 and 1 or 1", 1},
+		{"1 or 0 and 0", 1},
+		{"2 <= 2 and 2 >= 3", 0},
+		{"power_total", 100},
+		{"temp2", 50},
+		{"fan1 / 10", 100},
+		{`"coretemp-isa-0000::temp1::input" - "nct6775-isa-0290::temp1::input"`, 10},
+		{`"coretemp-*::temp2::*"`, 50},
+		{`max("coretemp-*::temp*::input")`, 50},
+		{`avg("coretemp-*::temp*::input")`, 45},
+		{`count("*::fan*::input")`, 2},
+		{`count("*::temp*::input")`, 3},
+		{`count("none::*")`, 0},
+		{`sum("*::fan*::input", 5)`, 1005},
+		{"min(fan1, fan2)", 0},
+		{"abs(-3)", 3},
+		{"max(temp2, power_total) - min(1, 2, 3)", 99},
+	} {
+		node, errPE := parseExpr(tc.source)
+		if errPE != nil {
+			t.Errorf("parseExpr(%s): %s", tc.source, errPE.Error())
+			continue
+		}
+
+		if actual, errEv := node.eval(newExprEnv(testExprPerfdata)); errEv != nil {
+			t.Errorf("%s: %s", tc.source, errEv.Error())
+		} else if actual != tc.expected {
+			t.Errorf("%s = %v; expected %v", tc.source, actual, tc.expected)
+		}
+	}
+}
+
+func TestParseExprErrors(t *testing.T) {
+	for _, tc := range []struct {
+		source   string
+		expected string
+	}{
+		{"", "unexpected end of expression"},
+		{"1 +", "unexpected end of expression"},
+		{"(1 + 2", "unexpected end of expression"},
+		{"1 + * 2", `column 5: unexpected "*"`},
+		{"1 2", `column 3: unexpected "2"`},
+		{"max(1 2)", `column 7: unexpected "2"`},
+		{"(1))", `column 4: unexpected ")"`},
+		{`1 + "abc`, "column 5: unterminated string"},
+		{"1 $ 2", "column 3: unexpected '$'"},
+		{"foo(1)", "column 1: unknown function foo()"},
+		{"1 + 1.2.3", `column 5: bad number "1.2.3"`},
+	} {
+		if _, errPE := parseExpr(tc.source); errPE == nil {
+			t.Errorf("parseExpr(%s): expected error %q", tc.source, tc.expected)
+		} else if errPE.Error() != tc.expected {
+			t.Errorf("parseExpr(%s): expected error %q, got %q", tc.source, tc.expected, errPE.Error())
+		}
+	}
+}
+
+func TestExprEvalErrors(t *testing.T) {
+	for _, tc := range []struct {
+		source      string
+		expected    string
+		unavailable bool
+	}{
+		{"1 / 0", "division by zero", false},
+		{"temp1", "ambiguous sensor: temp1", false},
+		{`"*::fan*::input"`, "ambiguous sensor: *::fan*::input", false},
+		{"temp9", "no such sensor: temp9", true},
+		{"temp3", "implausible sensor: temp3", true},
+		{`"nct6775-isa-0290::temp3::input"`, "implausible sensor: nct6775-isa-0290::temp3::input", true},
+		{`min("none::*")`, "no such sensor: none::*", true},
+		{`sum("none::*", 1)`, "no such sensor: none::*", true},
+		{`min("it87-isa-0a30::fan*::input") < 500`, "implausible sensor: it87-isa-0a30::fan*::input", true},
+		{`"it87-*::fan1::input"`, "implausible sensor: it87-*::fan1::input", true},
+		{"min()", "min() of no values", false},
+		{"abs(1, 2)", "abs() takes exactly one value", false},
+	} {
+		node, errPE := parseExpr(tc.source)
+		if errPE != nil {
+			t.Errorf("parseExpr(%s): %s", tc.source, errPE.Error())
+			continue
+		}
+
+		_, errEv := node.eval(newExprEnv(testExprPerfdata))
+		if errEv == nil {
+			t.Errorf("%s: expected error %q", tc.source, tc.expected)
+			continue
+		}
+
+		if errEv.Error() != tc.expected {
+			t.Errorf("%s: expected error %q, got %q", tc.source, tc.expected, errEv.Error())
+		}
+
+		if _, isUnavailable := errEv.(*unavailableSensorError); isUnavailable != tc.unavailable {
+			t.Errorf("%s: expected the sensor to be unavailable: %v", tc.source, tc.unavailable)
+		}
+	}
+}
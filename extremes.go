@@ -0,0 +1,281 @@
+package main
+
+import (
+	"fmt"
+	. "github.com/Al2Klimov/go-monplug-utils"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// hwmonDir is where the kernel exposes the hardware monitoring chips.
+const hwmonDir = "/sys/class/hwmon"
+
+// extremes are the lowest and highest input of a feature the plugin has seen.
+type extremes struct {
+	Since   time.Time `json:"since"`
+	Time    time.Time `json:"time"`
+	Lowest  float64   `json:"lowest"`
+	Highest float64   `json:"highest"`
+}
+
+// trackExtremes updates the lowest and highest input of a feature and returns them as statistics
+// unless the hardware reports them by itself.
+func trackExtremes(sf *stateFile, featurePerfdata PerfdataCollection, unit string) (stats [][2]string) {
+	if sf == nil {
+		return nil
+	}
+
+	if sf.data.Extremes == nil {
+		sf.data.Extremes = map[string]*extremes{}
+	}
+
+	hasLowest, hasHighest := false, false
+	var input *Perfdata
+
+	for i := range featurePerfdata {
+		switch label := featurePerfdata[i].Label; {
+		case isInputLabel(label):
+			input = &featurePerfdata[i]
+		case strings.HasSuffix(label, "::lowest"):
+			hasLowest = true
+		case strings.HasSuffix(label, "::highest"):
+			hasHighest = true
+		}
+	}
+
+	if input == nil {
+		return nil
+	}
+
+	ex, hasExtremes := sf.data.Extremes[input.Label]
+	if hasExtremes {
+		if input.Value < ex.Lowest {
+			ex.Lowest = input.Value
+		}
+
+		if input.Value > ex.Highest {
+			ex.Highest = input.Value
+		}
+
+		ex.Time = sf.now
+	} else {
+		ex = &extremes{Since: sf.now, Time: sf.now, Lowest: input.Value, Highest: input.Value}
+		sf.data.Extremes[input.Label] = ex
+	}
+
+	since := " (since " + ex.Since.Format("2006-01-02 15:04") + ")"
+
+	if !hasLowest {
+		stats = append(stats, [2]string{"Lowest" + since, fmtNum(ex.Lowest, unit)})
+	}
+
+	if !hasHighest {
+		stats = append(stats, [2]string{"Highest" + since, fmtNum(ex.Highest, unit)})
+	}
+
+	return
+}
+
+// resetHistory forgets the lowest and highest inputs (and the history for forecasts)
+// of the features matching any of the given "chip::feature" patterns (or of all features)
+// both in the state file and, where supported, in the hardware.
+func resetHistory(args []string) int {
+	sf, errs := openState()
+	if errs != nil {
+		printErrs(errs)
+		return 3
+	}
+
+	defer sf.close()
+
+	reset := 0
+
+	if sf != nil {
+		for label := range sf.data.Extremes {
+			if chip, feature := splitFeaturePattern(featureOf(label)); matchesFeature(args, chip, feature) {
+				delete(sf.data.Extremes, label)
+				reset++
+			}
+		}
+
+		for label := range sf.data.History {
+			if chip, feature := splitFeaturePattern(featureOf(label)); matchesFeature(args, chip, feature) {
+				delete(sf.data.History, label)
+			}
+		}
+
+		if errs := sf.save(); errs != nil {
+			printErrs(errs)
+			return 3
+		}
+	}
+
+	fmt.Printf("Reset %d feature(s) in the state file\n", reset)
+
+	resetHw, errs := resetHwmonHistory(args)
+	fmt.Printf("Reset %d feature(s) in the hardware\n", resetHw)
+
+	if errs != nil {
+		printErrs(errs)
+		return 3
+	}
+
+	return 0
+}
+
+// resetHwmonHistory writes to the *_reset_history attributes of the hardware monitoring chips.
+func resetHwmonHistory(patterns []string) (reset int, errs map[string]error) {
+	chips, errGl := filepath.Glob(filepath.Join(hwmonDir, "hwmon*"))
+	if errGl != nil {
+		return 0, map[string]error{"glob()": errGl}
+	}
+
+	for _, chip := range chips {
+		chipName, hasChipName := hwmonChipName(chip)
+		if !hasChipName && len(patterns) > 0 {
+			continue
+		}
+
+		attributes, errGl := filepath.Glob(filepath.Join(chip, "*reset_history"))
+		if errGl != nil {
+			return reset, map[string]error{"glob()": errGl}
+		}
+
+		for _, attribute := range attributes {
+			feature := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(attribute), "reset_history"), "_")
+
+			if !matchesHwmon(patterns, chipName, feature) {
+				continue
+			}
+
+			if errWF := ioutil.WriteFile(attribute, []byte("1\n"), 0); errWF != nil {
+				if errs == nil {
+					errs = map[string]error{}
+				}
+
+				errs["write("+attribute+")"] = errWF
+				continue
+			}
+
+			reset++
+		}
+	}
+
+	return
+}
+
+// hwmonChipName names a hardware monitoring chip like libsensors does, e.g. "nct6775-isa-0290",
+// based on the bus and address of its device.
+func hwmonChipName(chip string) (string, bool) {
+	rawPrefix, errRF := ioutil.ReadFile(filepath.Join(chip, "name"))
+	if errRF != nil {
+		return "", false
+	}
+
+	prefix := strings.TrimSpace(string(rawPrefix))
+
+	device, errES := filepath.EvalSymlinks(filepath.Join(chip, "device"))
+	if errES != nil {
+		return prefix + "-virtual-0", true
+	}
+
+	subsystem, errES := filepath.EvalSymlinks(filepath.Join(device, "subsystem"))
+	if errES != nil {
+		return "", false
+	}
+
+	deviceName := filepath.Base(device)
+	var domain, bus, slot, fn, nr, addr int
+
+	switch filepath.Base(subsystem) {
+	case "i2c":
+		if _, errSs := fmt.Sscanf(deviceName, "%d-%x", &nr, &addr); errSs == nil {
+			return fmt.Sprintf("%s-i2c-%d-%02x", prefix, nr, addr), true
+		}
+	case "spi":
+		if _, errSs := fmt.Sscanf(deviceName, "spi%d.%d", &nr, &addr); errSs == nil {
+			return fmt.Sprintf("%s-spi-%d-%x", prefix, nr, addr), true
+		}
+	case "pci":
+		if _, errSs := fmt.Sscanf(deviceName, "%x:%x:%x.%x", &domain, &bus, &slot, &fn); errSs == nil {
+			return fmt.Sprintf("%s-pci-%04x", prefix, domain<<16|bus<<8|slot<<3|fn), true
+		}
+	case "platform", "of_platform":
+		if dot := strings.LastIndex(deviceName, "."); dot >= 0 {
+			addr, _ = strconv.Atoi(deviceName[dot+1:])
+		}
+
+		return fmt.Sprintf("%s-isa-%04x", prefix, addr), true
+	case "acpi":
+		// libsensors doesn't tell ACPI devices apart.
+		return prefix + "-acpi-0", true
+	case "hid":
+		if _, errSs := fmt.Sscanf(deviceName, "%x:%x:%x.%x", &nr, &domain, &bus, &addr); errSs == nil {
+			return fmt.Sprintf("%s-hid-%d-%x", prefix, nr, addr), true
+		}
+	case "mdio_bus":
+		if colon := strings.LastIndex(deviceName, ":"); colon >= 0 {
+			if value, errPI := strconv.ParseInt(deviceName[colon+1:], 16, 64); errPI == nil {
+				return fmt.Sprintf("%s-mdio-%x", prefix, value), true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// matchesHwmon is like matchesFeature, but chip-wide attributes (feature "")
+// are only matched by patterns without a feature part.
+func matchesHwmon(patterns []string, chip, feature string) bool {
+	if len(patterns) < 1 {
+		return true
+	}
+
+	for _, pattern := range patterns {
+		chipPattern, featurePattern := splitFeaturePattern(pattern)
+		chipMatches, _ := path.Match(chipPattern, chip)
+		featureMatches := featurePattern == "*"
+
+		if feature != "" {
+			featureMatches, _ = path.Match(featurePattern, feature)
+		}
+
+		if chipMatches && featureMatches {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchesFeature tells whether a feature matches any of the "chip::feature" patterns (or there are none).
+func matchesFeature(patterns []string, chip, feature string) bool {
+	if len(patterns) < 1 {
+		return true
+	}
+
+	for _, pattern := range patterns {
+		chipPattern, featurePattern := splitFeaturePattern(pattern)
+		chipMatches, _ := path.Match(chipPattern, chip)
+		featureMatches, _ := path.Match(featurePattern, feature)
+
+		if chipMatches && featureMatches {
+			return true
+		}
+	}
+
+	return false
+}
+
+// splitFeaturePattern splits a "chip::feature" pattern. A pattern without a feature part matches all features.
+func splitFeaturePattern(pattern string) (chip, feature string) {
+	if sep := strings.Index(pattern, "::"); sep >= 0 {
+		return pattern[:sep], pattern[sep+2:]
+	}
+
+	return pattern, "*"
+}
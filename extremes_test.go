@@ -0,0 +1,85 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHwmonChipName(t *testing.T) {
+	sysfs, errTD := ioutil.TempDir("", "check_linux_sensors")
+	if errTD != nil {
+		t.Fatal(errTD)
+	}
+
+	defer os.RemoveAll(sysfs)
+
+	for _, tc := range []struct {
+		name      string
+		device    string
+		subsystem string
+		expected  string
+	}{
+		{"nct6775", "platform/nct6775.656", "platform", "nct6775-isa-0290"},
+		{"coretemp", "platform/coretemp.0", "platform", "coretemp-isa-0000"},
+		{"k10temp", "pci0000:00/0000:00:18.3", "pci", "k10temp-pci-00c3"},
+		{"lm75", "i2c-0/0-0048", "i2c", "lm75-i2c-0-48"},
+		{"acpitz", "LNXSYSTM:00/LNXTHERM:01", "acpi", "acpitz-acpi-0"},
+		{"iwlwifi_1", "", "", "iwlwifi_1-virtual-0"},
+	} {
+		chip := filepath.Join(sysfs, "class", "hwmon", tc.name)
+		mustMkdirAll(t, chip)
+
+		if errWF := ioutil.WriteFile(filepath.Join(chip, "name"), []byte(tc.name+"\n"), 0644); errWF != nil {
+			t.Fatal(errWF)
+		}
+
+		if tc.device != "" {
+			device := filepath.Join(sysfs, "devices", tc.device)
+			subsystem := filepath.Join(sysfs, "bus", tc.subsystem)
+
+			mustMkdirAll(t, device)
+			mustMkdirAll(t, subsystem)
+			mustSymlink(t, subsystem, filepath.Join(device, "subsystem"))
+			mustSymlink(t, device, filepath.Join(chip, "device"))
+		}
+
+		if actual, hasName := hwmonChipName(chip); !hasName || actual != tc.expected {
+			t.Errorf("hwmonChipName(%s) = %q, %v; expected %q", tc.name, actual, hasName, tc.expected)
+		}
+	}
+}
+
+func TestMatchesHwmon(t *testing.T) {
+	for _, tc := range []struct {
+		patterns []string
+		chip     string
+		feature  string
+		expected bool
+	}{
+		{nil, "nct6775-isa-0290", "fan1", true},
+		{[]string{"nct6775-isa-0290::fan1"}, "nct6775-isa-0290", "fan1", true},
+		{[]string{"nct6775-isa-0290::fan1"}, "nct6775-isa-0a20", "fan1", false},
+		{[]string{"nct6775-isa-0290::fan1"}, "nct6775-isa-0290", "fan2", false},
+		{[]string{"nct6775-isa-0290::fan1"}, "nct6775-isa-0290", "", false},
+		{[]string{"nct6775-*"}, "nct6775-isa-0290", "", true},
+		{[]string{"coretemp-*"}, "nct6775-isa-0290", "fan1", false},
+	} {
+		if actual := matchesHwmon(tc.patterns, tc.chip, tc.feature); actual != tc.expected {
+			t.Errorf("matchesHwmon(%q, %q, %q) = %v; expected %v", tc.patterns, tc.chip, tc.feature, actual, tc.expected)
+		}
+	}
+}
+
+func mustMkdirAll(t *testing.T, path string) {
+	if errMA := os.MkdirAll(path, 0755); errMA != nil {
+		t.Fatal(errMA)
+	}
+}
+
+func mustSymlink(t *testing.T, target, link string) {
+	if errSl := os.Symlink(target, link); errSl != nil && !os.IsExist(errSl) {
+		t.Fatal(errSl)
+	}
+}
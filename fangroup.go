@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	. "github.com/Al2Klimov/go-monplug-utils"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// fanGroup is a set of redundant fans of which at least required+spare shall be healthy.
+type fanGroup struct {
+	name     string
+	fans     []string
+	required int
+	spare    int
+}
+
+var fanGroups []fanGroup
+
+// parseFanGroupSection interprets a [fan-group NAME] section like:
+//
+//	fans = nct6775-isa-0290::fan*, it87-isa-0a30::fan[12]
+//	required = 4
+//	spare = 1
+//
+// required defaults to the number of fans found minus spare, spare defaults to 0.
+func parseFanGroupSection(section *configSection) error {
+	if section.name == "" {
+		return errorAt(section.line, errors.New("[fan-group] requires a name"))
+	}
+
+	for _, group := range fanGroups {
+		if group.name == section.name {
+			return errorAt(section.line, errors.New("duplicate fan group: "+section.name))
+		}
+	}
+
+	group := fanGroup{name: section.name, required: -1}
+
+	for _, entry := range section.entries {
+		switch entry.key {
+		case "fans":
+			for _, pattern := range strings.Split(entry.value, ",") {
+				pattern = strings.TrimSpace(pattern)
+
+				if _, errMt := path.Match(pattern, ""); errMt != nil {
+					return errorAt(entry.line, errMt)
+				}
+
+				group.fans = append(group.fans, pattern)
+			}
+		case "required", "spare":
+			count, errPI := strconv.ParseUint(entry.value, 10, 31)
+			if errPI != nil {
+				return errorAt(entry.line, errPI)
+			}
+
+			if entry.key == "required" {
+				group.required = int(count)
+			} else {
+				group.spare = int(count)
+			}
+		default:
+			return errorAt(entry.line, errors.New("unknown key: "+entry.key))
+		}
+	}
+
+	if len(group.fans) < 1 {
+		return errorAt(section.line, errors.New("fan group without fans: "+group.name))
+	}
+
+	fanGroups = append(fanGroups, group)
+	return nil
+}
+
+// evaluateFanGroups counts the healthy fans of each group.
+// A fan is healthy if it spins, not slower than its minimum, and doesn't report a fault.
+func evaluateFanGroups(features []featureReading) (groupStates PerfdataCollection) {
+	for _, group := range fanGroups {
+		total := 0
+		healthy := 0
+
+		for i := range features {
+			feature := &features[i]
+			if feature.kind != "fan" || !group.matches(feature) {
+				continue
+			}
+
+			total++
+
+			input, hasInput := feature.get("input")
+			if !hasInput || input.Value <= 0 || input.Min.IsSet && input.Value < input.Min.Value {
+				continue
+			}
+
+			if fault, hasFault := feature.get("fault"); hasFault && fault.Value == 1.0 {
+				continue
+			}
+
+			healthy++
+		}
+
+		required := group.required
+		if required < 0 {
+			required = total - group.spare
+
+			if required < 0 {
+				required = 0
+			}
+		}
+
+		groupStates = append(groupStates, Perfdata{
+			Label: pdl("fan_group", group.name, "healthy"),
+			Value: float64(healthy),
+			Warn:  OptionalThreshold{true, false, float64(required + group.spare), posInf},
+			Crit:  OptionalThreshold{true, false, float64(required), posInf},
+			Min:   OptionalNumber{true, 0},
+			Max:   OptionalNumber{true, float64(total)},
+		})
+	}
+
+	return
+}
+
+func (fg *fanGroup) matches(feature *featureReading) bool {
+	for _, pattern := range fg.fans {
+		if matches, _ := path.Match(pattern, pdl(feature.chip, feature.name)); matches {
+			return true
+		}
+	}
+
+	return false
+}
+
+func fmtFanGroup(pd Perfdata) string {
+	return fmt.Sprintf(
+		"%s of %s fans healthy (N+M: %s+%s)",
+		fmtNum(pd.Value, ""), fmtNum(pd.Max.Value, ""),
+		fmtNum(pd.Crit.Start, ""), fmtNum(pd.Warn.Start-pd.Crit.Start, ""),
+	)
+}
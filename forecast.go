@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	. "github.com/Al2Klimov/go-monplug-utils"
+	"strconv"
+	"time"
+)
+
+// forecastConfig tells how much history to extrapolate for the inputs of a feature kind and when to alert.
+type forecastConfig struct {
+	window      time.Duration
+	horizonWarn float64
+	horizonCrit float64
+}
+
+var forecastConfigs = map[string]forecastConfig{}
+
+// historySample is a UNIX timestamp and a value.
+type historySample [2]float64
+
+func (hs historySample) time() time.Time {
+	return time.Unix(0, int64(hs[0]*float64(time.Second)))
+}
+
+// parseForecastSection interprets a [forecast KIND] section like:
+//
+//	window = 30
+//	horizon-warn = 60
+//	horizon-crit = 15
+//
+// All of them are in minutes.
+func parseForecastSection(section *configSection) error {
+	if errRK := requireInputKind(section); errRK != nil {
+		return errRK
+	}
+
+	fc := forecastConfig{window: 30 * time.Minute}
+
+	for _, entry := range section.entries {
+		minutes, errPF := strconv.ParseFloat(entry.value, 64)
+		if errPF != nil {
+			return errorAt(entry.line, errPF)
+		}
+
+		if minutes <= 0 {
+			return errorAt(entry.line, errors.New(entry.key+" must be positive"))
+		}
+
+		switch entry.key {
+		case "window":
+			fc.window = time.Duration(minutes * float64(time.Minute))
+		case "horizon-warn":
+			fc.horizonWarn = minutes
+		case "horizon-crit":
+			fc.horizonCrit = minutes
+		default:
+			return errorAt(entry.line, errors.New("unknown key: "+entry.key))
+		}
+	}
+
+	forecastConfigs[section.name] = fc
+	return nil
+}
+
+// forecast extrapolates the linear trend of the inputs of the configured kinds
+// and reports in how many minutes they'll violate their critical thresholds
+// (or leave their minimum/maximum range if there are none).
+// Only the ones within a horizon are returned (and described) for the long output.
+func forecast(sf *stateFile, features []featureReading) (all, imminent PerfdataCollection, descriptions map[string]string) {
+	if sf.data.History == nil {
+		sf.data.History = map[string][]historySample{}
+	}
+
+	descriptions = map[string]string{}
+	now := float64(sf.now.UnixNano()) / float64(time.Second)
+
+	for i := range features {
+		feature := &features[i]
+
+		fc, isConfigured := forecastConfigs[feature.kind]
+		if !isConfigured {
+			continue
+		}
+
+		input, hasInput := feature.get("input")
+		if !hasInput {
+			continue
+		}
+
+		since := now - fc.window.Seconds()
+		history := []historySample{}
+
+		for _, sample := range sf.data.History[input.Label] {
+			if sample[0] >= since && sample[0] < now {
+				history = append(history, sample)
+			}
+		}
+
+		history = append(history, historySample{now, input.Value})
+		sf.data.History[input.Label] = history
+
+		if len(history) < 3 {
+			continue
+		}
+
+		start, end := negInf, posInf
+		if input.Crit.IsSet && !input.Crit.Inverted {
+			start, end = input.Crit.Start, input.Crit.End
+		} else {
+			if input.Min.IsSet {
+				start = input.Min.Value
+			}
+
+			if input.Max.IsSet {
+				end = input.Max.Value
+			}
+		}
+
+		slope := linearSlope(history) * 60
+		minutes := 0.0
+
+		switch {
+		case input.Value > end || input.Value < start:
+		case slope > 0 && end != posInf:
+			minutes = (end - input.Value) / slope
+		case slope < 0 && start != negInf:
+			minutes = (input.Value - start) / -slope
+		default:
+			continue
+		}
+
+		pd := Perfdata{Label: input.Label + "_minutes_left", Value: minutes, Min: OptionalNumber{true, 0}}
+
+		if fc.horizonWarn > 0 {
+			pd.Warn = OptionalThreshold{true, false, fc.horizonWarn, posInf}
+		}
+
+		if fc.horizonCrit > 0 {
+			pd.Crit = OptionalThreshold{true, false, fc.horizonCrit, posInf}
+		}
+
+		all = append(all, pd)
+
+		if perfdataState(pd) != stateOk {
+			unit := kindUnits[feature.kind]
+
+			imminent = append(imminent, pd)
+			descriptions[pd.Label] = fmt.Sprintf(
+				"%s, trend %s, %s minutes left", fmtNum(input.Value, unit), fmtNum(slope, unit+"/min"), fmtFloat(minutes),
+			)
+		}
+	}
+
+	return
+}
+
+// linearSlope returns the slope (per second) of the least squares regression line through the samples.
+func linearSlope(samples []historySample) float64 {
+	n := float64(len(samples))
+	t0 := samples[0][0]
+	sumT, sumV, sumTT, sumTV := 0.0, 0.0, 0.0, 0.0
+
+	for _, sample := range samples {
+		t := sample[0] - t0
+		sumT += t
+		sumV += sample[1]
+		sumTT += t * t
+		sumTV += t * sample[1]
+	}
+
+	denominator := n*sumTT - sumT*sumT
+	if denominator == 0 {
+		return 0
+	}
+
+	return (n*sumTV - sumT*sumV) / denominator
+}
+
+// fmtForecast describes an imminent threshold violation given the descriptions by forecast.
+func fmtForecast(descriptions map[string]string) func(Perfdata) string {
+	return func(pd Perfdata) string {
+		return descriptions[pd.Label]
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	. "github.com/Al2Klimov/go-monplug-utils"
+	"strconv"
+	"strings"
+)
+
+// hysteresisMargins are how far inputs of a feature kind have to get back into their thresholds' ranges
+// after having violated them to be considered OK again.
+var hysteresisMargins = map[string]float64{}
+
+// parseHysteresisSection interprets a [hysteresis KIND] section like:
+//
+//	margin = 3
+func parseHysteresisSection(section *configSection) error {
+	if errRK := requireInputKind(section); errRK != nil {
+		return errRK
+	}
+
+	for _, entry := range section.entries {
+		switch entry.key {
+		case "margin":
+			margin, errPF := strconv.ParseFloat(entry.value, 64)
+			if errPF != nil {
+				return errorAt(entry.line, errPF)
+			}
+
+			if margin < 0 {
+				return errorAt(entry.line, errors.New("margin must not be negative"))
+			}
+
+			hysteresisMargins[section.name] = margin
+		default:
+			return errorAt(entry.line, errors.New("unknown key: "+entry.key))
+		}
+	}
+
+	return nil
+}
+
+// applyHysteresis narrows the thresholds of a feature's input which has violated them on the previous run,
+// so that it stays in that state until it has got back beyond the hardware's critical hysteresis
+// (if given, for the upper critical threshold) or the configured margin.
+// It describes the held state if any.
+func applyHysteresis(sf *stateFile, kind string, featurePerfdata PerfdataCollection, critHyst OptionalNumber) string {
+	if sf == nil {
+		return ""
+	}
+
+	margin := hysteresisMargins[kind]
+
+	for i := range featurePerfdata {
+		input := &featurePerfdata[i]
+		if !isInputLabel(input.Label) {
+			continue
+		}
+
+		last, hasLast := sf.last(input.Label)
+		if !hasLast || last.State == stateOk {
+			return ""
+		}
+
+		threshold := &input.Warn
+		if last.State == stateCritical {
+			threshold = &input.Crit
+		}
+
+		if !threshold.IsSet || threshold.Inverted {
+			return ""
+		}
+
+		held := *threshold
+
+		// The side of the range violated previously, the value may be in it by now due to the hysteresis.
+		upper := held.End != posInf && (held.Start == negInf || last.Value > (held.Start+held.End)/2)
+
+		if upper {
+			if critHyst.IsSet && threshold == &input.Crit && critHyst.Value < held.End {
+				held.End = critHyst.Value
+			} else {
+				held.End -= margin
+			}
+		}
+
+		if !upper && held.Start != negInf {
+			held.Start += margin
+		}
+
+		if held.Start > held.End || held == *threshold {
+			return ""
+		}
+
+		*threshold = held
+
+		if !violates(held, input.Value) {
+			return ""
+		}
+
+		switch {
+		case held.Start == negInf:
+			return stateNames[last.State] + " until below " + fmtNum(held.End, kindUnits[kind])
+		case held.End == posInf:
+			return stateNames[last.State] + " until above " + fmtNum(held.Start, kindUnits[kind])
+		default:
+			return stateNames[last.State] + " until between " +
+				fmtNum(held.Start, kindUnits[kind]) + " and " + fmtNum(held.End, kindUnits[kind])
+		}
+	}
+
+	return ""
+}
+
+// holdMaxAlarm keeps the max alarm of a feature raised on the previous run raised
+// until the input has got below the hardware's maximum hysteresis (if given).
+// It describes the held alarm if any.
+func holdMaxAlarm(sf *stateFile, kind string, featurePerfdata PerfdataCollection, maxHyst OptionalNumber) string {
+	if sf == nil || !maxHyst.IsSet {
+		return ""
+	}
+
+	var input, alarm *Perfdata
+
+	for i := range featurePerfdata {
+		switch label := featurePerfdata[i].Label; {
+		case isInputLabel(label):
+			input = &featurePerfdata[i]
+		case strings.HasSuffix(label, "::max_alarm"):
+			alarm = &featurePerfdata[i]
+		}
+	}
+
+	if input == nil || alarm == nil || alarm.Value == 1 || input.Value <= maxHyst.Value {
+		return ""
+	}
+
+	if last, hasLast := sf.last(alarm.Label); !hasLast || last.Value != 1 {
+		return ""
+	}
+
+	alarm.Value = 1
+	return "max alarm until below " + fmtNum(maxHyst.Value, kindUnits[kind])
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// icinga2VarPrefix prefixes the custom variables the Icinga 2 CheckCommand maps to the flags.
+const icinga2VarPrefix = "linux_sensors_"
+
+// icinga2IgnoredFlags are the flags (or prefixes thereof ending with "-") which don't affect the check.
+var icinga2IgnoredFlags = []string{"output-format", "checkmk-", "otlp-", "mqtt-"}
+
+// printIcinga2Config prints the Icinga 2 CheckCommand definition matching the flags of this binary.
+func printIcinga2Config(args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "print-icinga2-config takes no arguments")
+		return 3
+	}
+
+	fmt.Print(icinga2CheckCommand())
+	return 0
+}
+
+// icinga2CheckCommand renders the CheckCommand with an argument per flag affecting the check.
+func icinga2CheckCommand() string {
+	buf := bytes.Buffer{}
+
+	buf.Write([]byte("object CheckCommand \"linux_sensors\" {\n"))
+	buf.Write([]byte("\timport \"plugin-check-command\"\n\n"))
+	buf.Write([]byte("\tcommand = [ PluginDir + \"/check_linux_sensors\" ]\n\n"))
+	buf.Write([]byte("\targuments = {\n"))
+
+	flag.CommandLine.VisitAll(func(f *flag.Flag) {
+		if isIcinga2Ignored(f.Name) {
+			return
+		}
+
+		macro := icinga2Macro(f.Name)
+
+		fmt.Fprintf(&buf, "\t\t%s = {\n", strconv.Quote("--"+f.Name))
+
+		if isBoolFlag(f) {
+			fmt.Fprintf(&buf, "\t\t\tset_if = %s\n", strconv.Quote(macro))
+		} else {
+			fmt.Fprintf(&buf, "\t\t\tvalue = %s\n", strconv.Quote(macro))
+		}
+
+		fmt.Fprintf(&buf, "\t\t\tdescription = %s\n", strconv.Quote(f.Usage))
+		buf.Write([]byte("\t\t}\n"))
+	})
+
+	buf.Write([]byte("\t}\n}\n"))
+
+	return buf.String()
+}
+
+// isIcinga2Ignored tells whether a flag is in icinga2IgnoredFlags.
+func isIcinga2Ignored(flagName string) bool {
+	for _, ignored := range icinga2IgnoredFlags {
+		if flagName == ignored || strings.HasSuffix(ignored, "-") && strings.HasPrefix(flagName, ignored) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// icinga2Macro returns the runtime macro of the custom variable for the given flag.
+func icinga2Macro(flagName string) string {
+	return "$" + icinga2VarPrefix + strings.Replace(flagName, "-", "_", -1) + "$"
+}
+
+// isBoolFlag tells whether a flag doesn't take a value.
+func isBoolFlag(f *flag.Flag) bool {
+	bf, isBF := f.Value.(interface{ IsBoolFlag() bool })
+	return isBF && bf.IsBoolFlag()
+}
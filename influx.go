@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	. "github.com/Al2Klimov/go-monplug-utils"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// influxMeasurement is the measurement of all lines printed with --output-format influx.
+const influxMeasurement = "linux_sensors"
+
+// influxEscaper escapes tag keys, tag values and field keys.
+var influxEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+// printInflux prints a line per feature in the InfluxDB line protocol.
+func printInflux() int {
+	_, perfdata, features, errs := readSensors(false)
+	if errs != nil {
+		printErrs(errs)
+		return 3
+	}
+
+	now := strconv.FormatInt(time.Now().UnixNano(), 10)
+	fields := map[string][]string{}
+
+	for _, pd := range perfdata {
+		feature := featureOf(pd.Label)
+		fieldKey := influxEscaper.Replace(strings.TrimPrefix(pd.Label, feature+"::"))
+
+		fields[feature] = append(fields[feature], influxField(fieldKey, pd.Value))
+
+		if isInputLabel(pd.Label) {
+			fields[feature] = append(fields[feature], influxLimitFields(pd)...)
+		}
+	}
+
+	for i := range features {
+		feature := &features[i]
+		featureFields := fields[pdl(feature.chip, feature.name)]
+
+		if len(featureFields) < 1 {
+			continue
+		}
+
+		line := bytes.Buffer{}
+
+		line.Write([]byte(influxMeasurement))
+
+		for _, tag := range [5][2]string{
+			{"adapter", feature.adapter},
+			{"chip", feature.chip},
+			{"feature", feature.name},
+			{"label", feature.label},
+			{"type", feature.kind},
+		} {
+			if tag[1] != "" {
+				line.Write([]byte{','})
+				line.Write([]byte(tag[0]))
+				line.Write([]byte{'='})
+				line.Write([]byte(influxEscaper.Replace(tag[1])))
+			}
+		}
+
+		line.Write([]byte{' '})
+		line.Write([]byte(strings.Join(featureFields, ",")))
+		line.Write([]byte{' '})
+		line.Write([]byte(now))
+
+		fmt.Println(line.String())
+	}
+
+	return 0
+}
+
+// influxLimitFields returns the minimum, maximum and thresholds of an input as fields.
+func influxLimitFields(input Perfdata) (fields []string) {
+	if input.Min.IsSet {
+		fields = append(fields, influxField("min", input.Min.Value))
+	}
+
+	if input.Max.IsSet {
+		fields = append(fields, influxField("max", input.Max.Value))
+	}
+
+	fields = append(fields, influxThresholdFields("warn", input.Warn)...)
+	fields = append(fields, influxThresholdFields("crit", input.Crit)...)
+
+	return
+}
+
+// influxThresholdFields returns the finite ends of a threshold as e.g. "lcrit" and "crit" fields.
+func influxThresholdFields(name string, threshold OptionalThreshold) (fields []string) {
+	if threshold.IsSet && !threshold.Inverted {
+		if threshold.Start != negInf {
+			fields = append(fields, influxField("l"+name, threshold.Start))
+		}
+
+		if threshold.End != posInf {
+			fields = append(fields, influxField(name, threshold.End))
+		}
+	}
+
+	return
+}
+
+func influxField(key string, value float64) string {
+	return key + "=" + strconv.FormatFloat(value, 'f', -1, 64)
+}
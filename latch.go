@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	. "github.com/Al2Klimov/go-monplug-utils"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+var latchPeriod = flag.Duration(
+	"latch-alarms", 0, "Keep reporting alarms seen in the state file as WARNING for this long (e.g. 24h)",
+)
+
+// validateLatching checks whether --latch-alarms has a state file to latch the alarms in.
+func validateLatching() error {
+	if *latchPeriod > 0 && !*useState && *stateFilePath == "" {
+		return errors.New("--latch-alarms requires --state or --state-file")
+	}
+
+	return nil
+}
+
+// latchedAlarm is an alarm (or fault) seen and not yet acknowledged or expired.
+type latchedAlarm struct {
+	First   time.Time `json:"first"`
+	Last    time.Time `json:"last"`
+	Count   int       `json:"count"`
+	Lowest  *float64  `json:"lowest,omitempty"`
+	Highest *float64  `json:"highest,omitempty"`
+}
+
+// isAlarm tells whether a perfdata label is the one of an alarm or fault subfeature.
+func isAlarm(label string) bool {
+	return strings.HasSuffix(label, "alarm") || strings.HasSuffix(label, "::fault")
+}
+
+// latchAlarms records the current alarms with the feature's input and reports all latched ones.
+func latchAlarms(sf *stateFile, features []featureReading) (latched PerfdataCollection) {
+	if sf.data.Latched == nil {
+		sf.data.Latched = map[string]*latchedAlarm{}
+	}
+
+	for i := range features {
+		feature := &features[i]
+		input, hasInput := feature.get("input")
+
+		for _, pd := range feature.perfdata {
+			if !isAlarm(pd.Label) || pd.Value != 1.0 {
+				continue
+			}
+
+			alarm, hasAlarm := sf.data.Latched[pd.Label]
+			if !hasAlarm {
+				alarm = &latchedAlarm{First: sf.now}
+				sf.data.Latched[pd.Label] = alarm
+			}
+
+			alarm.Last = sf.now
+			alarm.Count++
+
+			if hasInput {
+				if alarm.Lowest == nil || input.Value < *alarm.Lowest {
+					alarm.Lowest = &input.Value
+				}
+
+				if alarm.Highest == nil || input.Value > *alarm.Highest {
+					alarm.Highest = &input.Value
+				}
+			}
+		}
+	}
+
+	labels := make([]string, 0, len(sf.data.Latched))
+	for label, alarm := range sf.data.Latched {
+		if sf.now.Sub(alarm.Last) > *latchPeriod {
+			delete(sf.data.Latched, label)
+		} else if chipIsSelected(label[:strings.Index(label, "::")]) {
+			labels = append(labels, label)
+		}
+	}
+
+	sort.Strings(labels)
+
+	for _, label := range labels {
+		latched = append(latched, Perfdata{
+			Label: label + "_latched",
+			Value: 1,
+			Warn:  OptionalThreshold{true, false, 0, 0},
+			Min:   OptionalNumber{true, 0},
+			Max:   OptionalNumber{true, 1},
+		})
+	}
+
+	return
+}
+
+// fmtLatched describes a latched alarm given the units of the features.
+func fmtLatched(sf *stateFile, units map[string]string) func(Perfdata) string {
+	return func(pd Perfdata) string {
+		alarm := sf.data.Latched[strings.TrimSuffix(pd.Label, "_latched")]
+		description := fmt.Sprintf(
+			"%dx, first %s, last %s",
+			alarm.Count, alarm.First.Format(time.RFC3339), alarm.Last.Format(time.RFC3339),
+		)
+
+		if alarm.Lowest != nil && alarm.Highest != nil {
+			unit := units[featureOf(pd.Label)]
+			description += ", input " + fmtNum(*alarm.Lowest, unit) + " - " + fmtNum(*alarm.Highest, unit)
+		}
+
+		return description
+	}
+}
+
+// acknowledge forgets the latched alarms matching the given perfdata label globs (all if none given).
+func acknowledge(args []string) int {
+	sf, errs := openState()
+	if errs != nil {
+		printErrs(errs)
+		return 3
+	}
+
+	if sf == nil {
+		fmt.Fprintln(os.Stderr, "acknowledge requires --state or --state-file")
+		return 3
+	}
+
+	defer sf.close()
+
+	acknowledged := 0
+
+	for label := range sf.data.Latched {
+		matches := len(args) < 1
+
+		for _, pattern := range args {
+			if match, _ := path.Match(pattern, label); match {
+				matches = true
+				break
+			}
+		}
+
+		if matches {
+			delete(sf.data.Latched, label)
+			acknowledged++
+		}
+	}
+
+	if errs := sf.save(); errs != nil {
+		printErrs(errs)
+		return 3
+	}
+
+	fmt.Printf("Acknowledged %d latched alarm(s)\n", acknowledged)
+	return 0
+}
@@ -4,6 +4,7 @@ package main
 
 import (
 	"bytes"
+	"flag"
 	"fmt"
 	_ "github.com/Al2Klimov/go-gen-source-repos"
 	sensors "github.com/Al2Klimov/go-linux-sensors"
@@ -11,6 +12,7 @@ import (
 	"html"
 	"math"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 )
@@ -18,8 +20,92 @@ import (
 var posInf = math.Inf(1)
 var negInf = math.Inf(-1)
 
+var precision = flag.Int(
+	"precision", 3, "Round numbers in the long output to this many decimal places (-1: as many as needed)",
+)
+
+var outputFormat = flag.String("output-format", "nagios", "Print the readings as nagios, checkmk or influx")
+
+// outputFormats print the readings instead of the Nagio$ check plugin output.
+var outputFormats = map[string]func() int{
+	"checkmk": printCheckmk,
+	"influx":  printInflux,
+}
+
+// subcommands are run instead of the check if given as the first CLI argument.
+var subcommands = map[string]func(args []string) int{
+	"acknowledge":          acknowledge,
+	"reset-history":        resetHistory,
+	"print-icinga2-config": printIcinga2Config,
+	"discover":             discover,
+	"zabbix-discovery":     zabbixDiscovery,
+	"zabbix-get":           zabbixGet,
+	"collectd-exec":        collectdExec,
+	"munin":                munin,
+	"snmp-pass-persist":    snmpPassPersist,
+	"otlp-export":          otlpExport,
+	"mqtt":                 mqttPublish,
+}
+
 func main() {
-	os.Exit(ExecuteCheck(onTerminal, checkLinuxSensors))
+	args := os.Args[1:]
+	run := check
+
+	if name := filepath.Base(os.Args[0]); strings.HasPrefix(name, muninPluginPrefix) {
+		run = muninPlugin(strings.TrimPrefix(name, muninPluginPrefix))
+	} else if len(args) > 0 {
+		if subcommand, isSubcommand := subcommands[args[0]]; isSubcommand {
+			run = subcommand
+			args = args[1:]
+		}
+	}
+
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	if flag.CommandLine.Parse(args) != nil {
+		os.Exit(3)
+	}
+
+	if errLC := loadConfig(); errLC != nil {
+		fmt.Println(errLC.Error())
+		os.Exit(3)
+	}
+
+	if errVS := validateSampling(); errVS != nil {
+		fmt.Println(errVS.Error())
+		os.Exit(3)
+	}
+
+	if errVL := validateLatching(); errVL != nil {
+		fmt.Println(errVL.Error())
+		os.Exit(3)
+	}
+
+	os.Exit(run(flag.Args()))
+}
+
+func check(args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintf(os.Stderr, "unknown subcommand: %s\n", args[0])
+		return 3
+	}
+
+	if *outputFormat != "nagios" {
+		printOutput, isOutputFormat := outputFormats[*outputFormat]
+		if !isOutputFormat {
+			fmt.Fprintf(os.Stderr, "unknown output format: %s\n", *outputFormat)
+			return 3
+		}
+
+		return printOutput()
+	}
+
+	return ExecuteCheck(onTerminal, checkLinuxSensors)
+}
+
+func printErrs(errs map[string]error) {
+	for context, err := range errs {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", context, err.Error())
+	}
 }
 
 func onTerminal() (output string) {
@@ -31,6 +117,22 @@ func onTerminal() (output string) {
 }
 
 func checkLinuxSensors() (output string, perfdata PerfdataCollection, errs map[string]error) {
+	output, perfdata, _, errs = readSensors(true)
+	return
+}
+
+// readSensors walks all chips and features and does everything else the check does.
+// In addition to the check's output it returns what it has read from the single features.
+// Unless recordState is set, it only reads the state file (if any).
+func readSensors(recordState bool) (output string, perfdata PerfdataCollection, features []featureReading, errs map[string]error) {
+	state, errsOS := openState()
+	if errsOS != nil {
+		errs = errsOS
+		return
+	}
+
+	defer state.close()
+
 	sensors.Init(nil)
 	defer sensors.Cleanup()
 
@@ -38,7 +140,12 @@ func checkLinuxSensors() (output string, perfdata PerfdataCollection, errs map[s
 	longOutput := bytes.Buffer{}
 
 	{
-		chips := sensors.GetDetectedChips(nil)
+		chips, errsFC := filterChips(sensors.GetDetectedChips(nil))
+		if errsFC != nil {
+			errs = errsFC
+			return
+		}
+
 		perfdata = append(perfdata, Perfdata{
 			Label: "chips",
 			Value: float64(len(chips)),
@@ -51,6 +158,11 @@ func checkLinuxSensors() (output string, perfdata PerfdataCollection, errs map[s
 			return
 		}
 
+		if errsSI := sampleInputs(chips); errsSI != nil {
+			errs = errsSI
+			return
+		}
+
 		for _, chip := range chips {
 			chipNameRaw, errMB := chip.MarshalBinary()
 			if errMB != nil {
@@ -66,7 +178,8 @@ func checkLinuxSensors() (output string, perfdata PerfdataCollection, errs map[s
 			chipDesc.Write([]byte(html.EscapeString(chipName)))
 			chipDesc.Write([]byte("</b>"))
 
-			if adapterName, hasAdapterName := chip.GetBus().GetAdapterName(); hasAdapterName {
+			adapterName, hasAdapterName := chip.GetBus().GetAdapterName()
+			if hasAdapterName {
 				chipDesc.Write([]byte(" ("))
 				chipDesc.Write([]byte(html.EscapeString(adapterName)))
 				chipDesc.Write([]byte{')'})
@@ -82,6 +195,9 @@ func checkLinuxSensors() (output string, perfdata PerfdataCollection, errs map[s
 				featureHasAlarm := false
 				featureHasFault := false
 				featureStats := [][2]string{}
+				featurePerfdataStart := len(perfdata)
+				featureCritHyst := OptionalNumber{}
+				featureMaxHyst := OptionalNumber{}
 
 				switch feature.GetType() {
 				case sensors.FeatureIn:
@@ -509,6 +625,21 @@ func checkLinuxSensors() (output string, perfdata PerfdataCollection, errs map[s
 							return
 						}
 
+						vMaxHyst, errsMaxHyst := getOptionalValue(chip, feature, sensors.SubfeatureTempMaxHyst)
+						if errsMaxHyst != nil {
+							errs = errsMaxHyst
+							return
+						}
+
+						vCritHyst, errsCritHyst := getOptionalValue(chip, feature, sensors.SubfeatureTempCritHyst)
+						if errsCritHyst != nil {
+							errs = errsCritHyst
+							return
+						}
+
+						featureCritHyst = vCritHyst
+						featureMaxHyst = vMaxHyst
+
 						perfdata = append(perfdata, Perfdata{
 							Label: pdl(chipName, featureName, "input"),
 							Value: vInput,
@@ -527,6 +658,12 @@ func checkLinuxSensors() (output string, perfdata PerfdataCollection, errs map[s
 							featureStats = append(featureStats, [2]string{"Maximum", fmtNum(vMax.Value, "deg. C")})
 						}
 
+						if vMaxHyst.IsSet {
+							featureStats = append(featureStats, [2]string{
+								"Maximum, hysteresis", fmtNum(vMaxHyst.Value, "deg. C"),
+							})
+						}
+
 						if vCrit.IsSet {
 							if vCrit.Start != negInf {
 								featureStats = append(featureStats, [2]string{
@@ -540,6 +677,12 @@ func checkLinuxSensors() (output string, perfdata PerfdataCollection, errs map[s
 								})
 							}
 						}
+
+						if vCritHyst.IsSet {
+							featureStats = append(featureStats, [2]string{
+								"Critical, hysteresis", fmtNum(vCritHyst.Value, "deg. C"),
+							})
+						}
 					}
 
 					if hasLowest {
@@ -1164,12 +1307,63 @@ func checkLinuxSensors() (output string, perfdata PerfdataCollection, errs map[s
 				}
 
 				if featureIsSupported {
+					featureIsPlausible := markImplausible(
+						chipName, featureName, featureKind(feature), perfdata[featurePerfdataStart:],
+					)
+
+					if featureIsPlausible {
+						if held := applyHysteresis(
+							state, featureKind(feature), perfdata[featurePerfdataStart:], featureCritHyst,
+						); held != "" {
+							featureStats = append(featureStats, [2]string{"Hysteresis", held})
+						}
+
+						if held := holdMaxAlarm(
+							state, featureKind(feature), perfdata[featurePerfdataStart:], featureMaxHyst,
+						); held != "" {
+							featureStats = append(featureStats, [2]string{"Hysteresis", held})
+							featureHasAlarm = true
+						}
+					}
+
+					if spread, hasSpread := sampled.spreads[pdl(chipName, featureName, "input")]; hasSpread {
+						perfdata = append(perfdata, Perfdata{
+							Label: pdl(chipName, featureName, "input_spread"),
+							Value: spread,
+							Min:   OptionalNumber{true, 0},
+						})
+
+						featureStats = append(featureStats, [2]string{
+							"Spread of " + strconv.Itoa(*samples) + " samples", fmtNum(spread, kindUnits[featureKind(feature)]),
+						})
+					}
+
+					if featureIsPlausible {
+						featureStats = append(featureStats, trackExtremes(
+							state, perfdata[featurePerfdataStart:], kindUnits[featureKind(feature)],
+						)...)
+					} else {
+						perfdata = append(perfdata, Perfdata{
+							Label: pdl(chipName, featureName, "input_implausible"),
+							Value: 1,
+							Min:   OptionalNumber{true, 0},
+							Max:   OptionalNumber{true, 1},
+						})
+
+						for i := range featureStats {
+							if featureStats[i][0] == "Input" {
+								featureStats[i][1] += " (implausible)"
+							}
+						}
+					}
+
 					featureDesc := bytes.Buffer{}
 
 					featureDesc.Write([]byte("<p>Feature: "))
 					featureDesc.Write([]byte(html.EscapeString(featureName)))
 
-					if label, hasLabel := chip.GetLabel(feature); hasLabel && label != featureName {
+					label, hasLabel := chip.GetLabel(feature)
+					if hasLabel && label != featureName {
 						featureDesc.Write([]byte(" ("))
 						featureDesc.Write([]byte(html.EscapeString(label)))
 						featureDesc.Write([]byte{')'})
@@ -1179,29 +1373,35 @@ func checkLinuxSensors() (output string, perfdata PerfdataCollection, errs map[s
 						featureDesc.Write([]byte(` <b style="color: #f70000;">FAULT</b>`))
 					} else if featureHasAlarm {
 						featureDesc.Write([]byte(` <b style="color: #f70000;">ALARM</b>`))
+					} else if !featureIsPlausible {
+						featureDesc.Write([]byte(` <b style="color: #f7a000;">IMPLAUSIBLE</b>`))
 					}
 
 					featureDesc.Write([]byte("</p>"))
 
 					longOutput.Write(featureDesc.Bytes())
 
-					if featureHasFault || featureHasAlarm {
+					if featureHasFault || featureHasAlarm || !featureIsPlausible {
 						chipOutput.Write(featureDesc.Bytes())
 					}
 
-					if len(featureStats) > 0 {
-						longOutput.Write([]byte("<table><tbody>"))
+					writeTable(&longOutput, featureStats)
 
-						for _, stat := range featureStats {
-							longOutput.Write([]byte("<tr><td>"))
-							longOutput.Write([]byte(html.EscapeString(stat[0])))
-							longOutput.Write([]byte("</td><td>"))
-							longOutput.Write([]byte(html.EscapeString(stat[1])))
-							longOutput.Write([]byte("</td></tr>"))
-						}
-
-						longOutput.Write([]byte("</tbody></table>"))
+					if !hasLabel {
+						label = featureName
 					}
+
+					features = append(features, featureReading{
+						chip:          chipName,
+						adapter:       adapterName,
+						name:          featureName,
+						label:         label,
+						kind:          featureKind(feature),
+						perfdata:      append(PerfdataCollection(nil), perfdata[featurePerfdataStart:]...),
+						hasAlarm:      featureHasAlarm,
+						hasFault:      featureHasFault,
+						isImplausible: !featureIsPlausible,
+					})
 				}
 			}
 
@@ -1213,6 +1413,91 @@ func checkLinuxSensors() (output string, perfdata PerfdataCollection, errs map[s
 		}
 	}
 
+	{
+		aggregates := aggregate(features)
+		perfdata = append(perfdata, aggregates...)
+
+		writeSection(&shortOutput, &longOutput, "Aggregates", aggregates, fmtAggregate)
+	}
+
+	if *chipFilter == "" {
+		groupStates := evaluateFanGroups(features)
+		perfdata = append(perfdata, groupStates...)
+
+		writeSection(&shortOutput, &longOutput, "Fan groups", groupStates, fmtFanGroup)
+	}
+
+	if state != nil {
+		rates := computeRates(state, features)
+		perfdata = append(perfdata, rates...)
+
+		writeSection(&shortOutput, &longOutput, "Rates of change", rates, fmtRate(featureUnits(features)))
+
+		powers := derivePower(state, features)
+		perfdata = append(perfdata, powers...)
+
+		writeSection(&shortOutput, &longOutput, "Power derived from energy", powers, fmtPowerDerived)
+
+		allStale, stale := detectStale(state, features)
+		perfdata = append(perfdata, allStale...)
+
+		writeSection(&shortOutput, &longOutput, "Stale sensors", stale, fmtStale(state, features))
+
+		allDeviations, anomalous, anomalies := detectAnomalies(state, features)
+		perfdata = append(perfdata, allDeviations...)
+
+		writeSection(&shortOutput, &longOutput, "Anomalies", anomalous, fmtAnomaly(anomalies))
+
+		allForecasts, imminent, forecasts := forecast(state, features)
+		perfdata = append(perfdata, allForecasts...)
+
+		writeSection(&shortOutput, &longOutput, "Forecasts", imminent, fmtForecast(forecasts))
+
+		if *latchPeriod > 0 {
+			latched := latchAlarms(state, features)
+			perfdata = append(perfdata, latched...)
+
+			writeSection(
+				&shortOutput, &longOutput, "Latched alarms", latched, fmtLatched(state, featureUnits(features)),
+			)
+		}
+	}
+
+	if *chipFilter == "" {
+		virtuals, skipped, errsEV := evaluateVirtuals(perfdata)
+		if errsEV != nil {
+			errs = errsEV
+			return
+		}
+
+		perfdata = append(perfdata, virtuals...)
+
+		writeSection(&shortOutput, &longOutput, "Virtual sensors", virtuals, fmtVirtual)
+		writeSkipped(&longOutput, "Skipped virtual sensors", skipped)
+	}
+
+	if *chipFilter == "" {
+		ruleStates, skipped, errsER := evaluateRules(perfdata)
+		if errsER != nil {
+			errs = errsER
+			return
+		}
+
+		perfdata = append(perfdata, ruleStates...)
+
+		writeSection(&shortOutput, &longOutput, "Rules", ruleStates, fmtRule)
+		writeSkipped(&longOutput, "Skipped rules", skipped)
+	}
+
+	if state != nil && recordState {
+		state.record(perfdata)
+
+		if errsSv := state.save(); errsSv != nil {
+			errs = errsSv
+			return
+		}
+	}
+
 	shortOutput.Write([]byte("\n\n<hr>"))
 	longOutput.WriteTo(&shortOutput)
 
@@ -1222,6 +1507,10 @@ func checkLinuxSensors() (output string, perfdata PerfdataCollection, errs map[s
 
 func getValue(chip *sensors.ChipName, feature sensors.Feature, typ sensors.SubfeatureType) (float64, bool, map[string]error) {
 	if subfeature, hasSubfeature := chip.GetSubfeature(feature, typ); hasSubfeature {
+		if value, isSampled := sampled.values[sampleKey{chip, subfeature.GetNumber()}]; isSampled {
+			return value, true, nil
+		}
+
 		if value, errGV := chip.GetValue(subfeature.GetNumber()); errGV == nil {
 			return value, true, nil
 		} else {
@@ -1284,10 +1573,192 @@ func getOptionalThreshold(chip *sensors.ChipName, feature sensors.Feature, typeS
 	return threshold, nil
 }
 
+// featureKind tells the kind of a feature checkLinuxSensors supports or "" if it doesn't support it.
+func featureKind(feature sensors.Feature) string {
+	switch feature.GetType() {
+	case sensors.FeatureIn:
+		return "in"
+	case sensors.FeatureVid:
+		return "vid"
+	case sensors.FeatureFan:
+		return "fan"
+	case sensors.FeatureTemp:
+		return "temp"
+	case sensors.FeatureCurr:
+		return "curr"
+	case sensors.FeaturePower:
+		return "power"
+	case sensors.FeatureEnergy:
+		return "energy"
+	case sensors.FeatureHumidity:
+		return "humidity"
+	case sensors.FeatureIntrusion:
+		return "intrusion"
+	default:
+		return ""
+	}
+}
+
+// kindUnits maps feature kinds to the units of their values in the long output.
+var kindUnits = map[string]string{
+	"in":       "V",
+	"vid":      "V",
+	"fan":      "RPM",
+	"temp":     "deg. C",
+	"curr":     "A",
+	"power":    "W",
+	"energy":   "J",
+	"humidity": "%",
+}
+
+// inputKinds are the feature kinds which have inputs.
+var inputKinds = map[string]struct{}{
+	"in": {}, "fan": {}, "temp": {}, "curr": {}, "power": {}, "energy": {}, "humidity": {},
+}
+
+// requireInputKind fails unless a configuration section is named after a feature kind which has inputs.
+func requireInputKind(section *configSection) error {
+	if _, isKind := inputKinds[section.name]; !isKind {
+		return errorAt(section.line, fmt.Errorf(
+			"[%s] requires one of: in, fan, temp, curr, power, energy, humidity", section.kind,
+		))
+	}
+
+	return nil
+}
+
 func pdl(perfdataComponents ...string) string {
 	return strings.Join(perfdataComponents, "::")
 }
 
+func writeTable(out *bytes.Buffer, rows [][2]string) {
+	if len(rows) > 0 {
+		out.Write([]byte("<table><tbody>"))
+
+		for _, row := range rows {
+			out.Write([]byte("<tr><td>"))
+			out.Write([]byte(html.EscapeString(row[0])))
+			out.Write([]byte("</td><td>"))
+			out.Write([]byte(html.EscapeString(row[1])))
+			out.Write([]byte("</td></tr>"))
+		}
+
+		out.Write([]byte("</tbody></table>"))
+	}
+}
+
+// writeSkipped lists what couldn't be evaluated and why (if anything).
+func writeSkipped(longOutput *bytes.Buffer, title string, skipped [][2]string) {
+	if len(skipped) > 0 {
+		longOutput.Write([]byte("<p><b>"))
+		longOutput.Write([]byte(html.EscapeString(title)))
+		longOutput.Write([]byte("</b></p>"))
+		writeTable(longOutput, skipped)
+	}
+}
+
+// writeSection renders perfdata derived from the sensors' readings
+// into the long output and the violated ones also into the short output.
+func writeSection(shortOutput, longOutput *bytes.Buffer, title string, section PerfdataCollection, fmtValue func(Perfdata) string) {
+	if len(section) < 1 {
+		return
+	}
+
+	rows := make([][2]string, 0, len(section))
+	alerts := bytes.Buffer{}
+
+	for _, pd := range section {
+		rows = append(rows, [2]string{pd.Label, fmtValue(pd)})
+
+		if state := perfdataState(pd); state != stateOk {
+			alerts.Write([]byte("<p>"))
+			alerts.Write([]byte(html.EscapeString(pd.Label)))
+			alerts.Write([]byte(stateBadges[state]))
+			alerts.Write([]byte("</p>"))
+		}
+	}
+
+	longOutput.Write([]byte("<p><b>"))
+	longOutput.Write([]byte(html.EscapeString(title)))
+	longOutput.Write([]byte("</b></p>"))
+	writeTable(longOutput, rows)
+
+	if alerts.Len() > 0 {
+		shortOutput.Write([]byte("<p><b>"))
+		shortOutput.Write([]byte(html.EscapeString(title)))
+		shortOutput.Write([]byte("</b></p>"))
+		alerts.WriteTo(shortOutput)
+	}
+}
+
+// siPrefixes are the SI prefixes fmtNum chooses from, the largest first.
+var siPrefixes = []struct {
+	factor float64
+	symbol string
+}{
+	{1e12, "T"},
+	{1e9, "G"},
+	{1e6, "M"},
+	{1e3, "k"},
+	{1, ""},
+	{1e-3, "m"},
+	{1e-6, "µ"},
+	{1e-9, "n"},
+}
+
+// siUnits maps the units fmtNum scales to the largest prefix factor allowed for them.
+var siUnits = map[string]float64{"V": 1e12, "A": 1e12, "W": 1e12, "J": 1e12, "s": 1}
+
+const joulesPerKWh = 3.6e6
+
 func fmtNum(num float64, unit string) string {
-	return strconv.FormatFloat(num, 'f', -1, 64) + " " + unit
+	maxFactor, isSI := siUnits[unit]
+	if unit == "" {
+		return fmtFloat(num)
+	}
+
+	if !isSI || num == 0 || math.IsInf(num, 0) || math.IsNaN(num) {
+		return fmtFloat(num) + " " + unit
+	}
+
+	scaled := fmtFloat(num)
+	symbol := ""
+
+	for _, prefix := range siPrefixes {
+		if prefix.factor > maxFactor {
+			continue
+		}
+
+		candidate := fmtFloat(num / prefix.factor)
+		if abs, _ := strconv.ParseFloat(candidate, 64); math.Abs(abs) >= 1 {
+			scaled = candidate
+			symbol = prefix.symbol
+			break
+		}
+
+		scaled = candidate
+		symbol = prefix.symbol
+	}
+
+	formatted := scaled + " " + symbol + unit
+
+	if unit == "J" && math.Abs(num) >= joulesPerKWh {
+		formatted += " (" + fmtFloat(num/joulesPerKWh) + " kWh)"
+	}
+
+	return formatted
+}
+
+func fmtFloat(num float64) string {
+	formatted := strconv.FormatFloat(num, 'f', *precision, 64)
+
+	if *precision > 0 {
+		formatted = strings.TrimRight(strings.TrimRight(formatted, "0"), ".")
+	}
+
+	if formatted == "-0" {
+		formatted = "0"
+	}
+
+	return formatted
 }
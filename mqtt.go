@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+var mqttBroker = flag.String("mqtt-broker", "localhost:1883", "Publish to this MQTT broker (host:port)")
+var mqttUsername = flag.String("mqtt-username", "", "Authenticate at the MQTT broker as this user")
+var mqttPassword = flag.String("mqtt-password", "", "Authenticate at the MQTT broker with this password")
+var mqttTopicPrefix = flag.String("mqtt-topic-prefix", "linux_sensors", "Publish under this MQTT topic prefix")
+var mqttDiscoveryPrefix = flag.String(
+	"mqtt-discovery-prefix", "", "Publish Home Assistant MQTT discovery payloads under this prefix (e.g. homeassistant)",
+)
+var mqttInterval = flag.Duration("mqtt-interval", 0, "Publish this often instead of once")
+
+// mqttKeepAlive is the keep alive announced to the MQTT broker.
+const mqttKeepAlive = 60
+
+// mqttTopicLevel matches what's not allowed in the topic levels and IDs derived from chip and feature names.
+var mqttTopicLevel = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// homeAssistantSensor describes the Home Assistant sensors of a kind of features.
+type homeAssistantSensor struct {
+	deviceClass string
+	unit        string
+	stateClass  string
+}
+
+var homeAssistantSensors = map[string]homeAssistantSensor{
+	"in":       {"voltage", "V", "measurement"},
+	"fan":      {"", "rpm", "measurement"},
+	"temp":     {"temperature", "°C", "measurement"},
+	"curr":     {"current", "A", "measurement"},
+	"power":    {"power", "W", "measurement"},
+	"energy":   {"energy", "J", "total_increasing"},
+	"humidity": {"humidity", "%", "measurement"},
+}
+
+// mqttMessage is an MQTT message to be published.
+type mqttMessage struct {
+	topic   string
+	payload []byte
+	retain  bool
+}
+
+// mqttPublish publishes the inputs and alarm states once or every --mqtt-interval.
+func mqttPublish(args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "mqtt takes no arguments")
+		return 3
+	}
+
+	host, errHn := os.Hostname()
+	if errHn != nil {
+		printErrs(map[string]error{"os.Hostname()": errHn})
+		return 3
+	}
+
+	for {
+		start := time.Now()
+
+		_, _, features, errs := readSensors(false)
+		if errs == nil {
+			errs = mqttSend(host, mqttMessages(host, features))
+		}
+
+		if *mqttInterval <= 0 {
+			if errs != nil {
+				printErrs(errs)
+				return 3
+			}
+
+			return 0
+		}
+
+		if errs != nil {
+			printErrs(errs)
+		}
+
+		time.Sleep(*mqttInterval - time.Since(start))
+	}
+}
+
+// mqttMessages returns the discovery payloads (if enabled), the inputs and the alarm states of the features.
+func mqttMessages(host string, features []featureReading) (messages []mqttMessage) {
+	node := mqttTopicLevel.ReplaceAllString(host, "_")
+
+	for i := range features {
+		feature := &features[i]
+
+		sensor, isSensor := homeAssistantSensors[feature.kind]
+		if !isSensor {
+			continue
+		}
+
+		chip := mqttTopicLevel.ReplaceAllString(feature.chip, "_")
+		topic := *mqttTopicPrefix + "/" + node + "/" + chip + "/" + mqttTopicLevel.ReplaceAllString(feature.name, "_")
+		object := chip + "_" + mqttTopicLevel.ReplaceAllString(feature.name, "_")
+
+		if *mqttDiscoveryPrefix != "" {
+			device := map[string]interface{}{
+				"identifiers": []string{node + "_" + chip},
+				"name":        host + " " + feature.chip,
+			}
+
+			if feature.adapter != "" {
+				device["model"] = feature.adapter
+			}
+
+			config := map[string]interface{}{
+				"name":                feature.label,
+				"unique_id":           node + "_" + object,
+				"state_topic":         topic + "/state",
+				"unit_of_measurement": sensor.unit,
+				"state_class":         sensor.stateClass,
+				"device":              device,
+			}
+
+			if sensor.deviceClass != "" {
+				config["device_class"] = sensor.deviceClass
+			}
+
+			messages = append(messages, mqttDiscoveryMessage("sensor", node, object, config))
+
+			messages = append(messages, mqttDiscoveryMessage("binary_sensor", node, object+"_alarm", map[string]interface{}{
+				"name":         feature.label + " alarm",
+				"unique_id":    node + "_" + object + "_alarm",
+				"state_topic":  topic + "/alarm",
+				"device_class": "problem",
+				"payload_on":   "ON",
+				"payload_off":  "OFF",
+				"device":       device,
+			}))
+		}
+
+		if input, hasInput := feature.get("input"); hasInput {
+			messages = append(messages, mqttMessage{
+				topic:   topic + "/state",
+				payload: []byte(strconv.FormatFloat(input.Value, 'f', -1, 64)),
+			})
+		}
+
+		alarm := "OFF"
+		if feature.hasAlarm || feature.hasFault {
+			alarm = "ON"
+		}
+
+		messages = append(messages, mqttMessage{topic: topic + "/alarm", payload: []byte(alarm)})
+	}
+
+	return
+}
+
+// mqttDiscoveryMessage returns a retained Home Assistant MQTT discovery payload.
+func mqttDiscoveryMessage(component, node, object string, config map[string]interface{}) mqttMessage {
+	payload, _ := json.Marshal(config)
+
+	return mqttMessage{
+		topic:   *mqttDiscoveryPrefix + "/" + component + "/" + node + "/" + object + "/config",
+		payload: payload,
+		retain:  true,
+	}
+}
+
+// mqttSend connects to the broker, publishes the messages with QoS 0 and disconnects.
+func mqttSend(host string, messages []mqttMessage) map[string]error {
+	conn, errDl := net.DialTimeout("tcp", *mqttBroker, 10*time.Second)
+	if errDl != nil {
+		return map[string]error{"dial(" + *mqttBroker + ")": errDl}
+	}
+
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(time.Minute))
+
+	out := bufio.NewWriter(conn)
+
+	if errCn := mqttConnect(out, conn, "check_linux_sensors-"+host); errCn != nil {
+		return map[string]error{"MQTT CONNECT": errCn}
+	}
+
+	for _, message := range messages {
+		header := byte(0x30)
+		if message.retain {
+			header |= 1
+		}
+
+		variable := bytes.Buffer{}
+		mqttString(&variable, []byte(message.topic))
+		variable.Write(message.payload)
+
+		mqttPacket(out, header, variable.Bytes())
+	}
+
+	mqttPacket(out, 0xe0, nil)
+
+	if errFl := out.Flush(); errFl != nil {
+		return map[string]error{"MQTT PUBLISH": errFl}
+	}
+
+	return nil
+}
+
+// mqttConnect sends CONNECT and awaits a successful CONNACK.
+func mqttConnect(out *bufio.Writer, in io.Reader, clientId string) error {
+	flags := byte(0x02)
+	payload := bytes.Buffer{}
+
+	mqttString(&payload, []byte(clientId))
+
+	if *mqttUsername != "" {
+		flags |= 0x80
+		mqttString(&payload, []byte(*mqttUsername))
+
+		if *mqttPassword != "" {
+			flags |= 0x40
+			mqttString(&payload, []byte(*mqttPassword))
+		}
+	}
+
+	variable := bytes.Buffer{}
+	mqttString(&variable, []byte("MQTT"))
+	variable.Write([]byte{4, flags, mqttKeepAlive >> 8, mqttKeepAlive & 0xff})
+	payload.WriteTo(&variable)
+
+	mqttPacket(out, 0x10, variable.Bytes())
+
+	if errFl := out.Flush(); errFl != nil {
+		return errFl
+	}
+
+	connAck := [4]byte{}
+	if _, errRF := io.ReadFull(in, connAck[:]); errRF != nil {
+		return errRF
+	}
+
+	if connAck[0] != 0x20 || connAck[1] != 2 {
+		return errors.New("unexpected response")
+	}
+
+	if connAck[3] != 0 {
+		return fmt.Errorf("connection refused (return code %d)", connAck[3])
+	}
+
+	return nil
+}
+
+// mqttPacket writes a control packet with the remaining length encoded as variable byte integer.
+func mqttPacket(out *bufio.Writer, header byte, rest []byte) {
+	out.WriteByte(header)
+
+	length := len(rest)
+	for {
+		digit := byte(length % 128)
+		length /= 128
+
+		if length > 0 {
+			digit |= 0x80
+		}
+
+		out.WriteByte(digit)
+
+		if length == 0 {
+			break
+		}
+	}
+
+	out.Write(rest)
+}
+
+// mqttString writes a length-prefixed UTF-8 string.
+func mqttString(buf *bytes.Buffer, s []byte) {
+	buf.Write([]byte{byte(len(s) >> 8), byte(len(s))})
+	buf.Write(s)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	. "github.com/Al2Klimov/go-monplug-utils"
+	"os"
+	"regexp"
+	"strconv"
+)
+
+// muninPluginPrefix is the prefix of the names this binary may be symlinked as to be a Munin plugin,
+// e.g. linux_sensors_temp.
+const muninPluginPrefix = "linux_sensors_"
+
+// muninGraph describes the graph of a kind of features.
+type muninGraph struct {
+	title string
+	unit  string
+}
+
+var muninGraphs = map[string]muninGraph{
+	"in":       {"Voltages", "V"},
+	"fan":      {"Fan speeds", "RPM"},
+	"temp":     {"Temperatures", "deg. C"},
+	"curr":     {"Currents", "A"},
+	"power":    {"Power", "W"},
+	"energy":   {"Energy", "J"},
+	"humidity": {"Humidity", "%"},
+}
+
+// muninKinds is the order of the graphs.
+var muninKinds = []string{"in", "fan", "temp", "curr", "power", "energy", "humidity"}
+
+// muninFieldName matches what's not allowed in Munin field names.
+var muninFieldName = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// muninPlugin returns what runs the binary symlinked as linux_sensors_KIND.
+func muninPlugin(kind string) func(args []string) int {
+	return func(args []string) int {
+		mode := "fetch"
+		if len(args) > 0 {
+			mode = args[0]
+		}
+
+		if _, isKind := muninGraphs[kind]; !isKind {
+			fmt.Fprintf(os.Stderr, "unknown feature type: %s\n", kind)
+			return 3
+		}
+
+		return munin([]string{mode, kind})
+	}
+}
+
+// munin speaks the Munin plugin protocol. Without any feature types given,
+// it prints all graphs as multigraph.
+func munin(args []string) int {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "usage: munin config|fetch [TYPE...]")
+		return 3
+	}
+
+	mode, kinds := args[0], args[1:]
+
+	switch mode {
+	case "config", "fetch":
+	default:
+		fmt.Fprintf(os.Stderr, "unknown mode: %s\n", mode)
+		return 3
+	}
+
+	for _, kind := range kinds {
+		if _, isKind := muninGraphs[kind]; !isKind {
+			fmt.Fprintf(os.Stderr, "unknown feature type: %s\n", kind)
+			return 3
+		}
+	}
+
+	_, _, features, errs := readSensors(false)
+	if errs != nil {
+		printErrs(errs)
+		return 3
+	}
+
+	byKind := map[string][]*featureReading{}
+
+	for i := range features {
+		byKind[features[i].kind] = append(byKind[features[i].kind], &features[i])
+	}
+
+	multigraph := len(kinds) < 1
+	if multigraph {
+		for _, kind := range muninKinds {
+			if len(byKind[kind]) > 0 {
+				kinds = append(kinds, kind)
+			}
+		}
+	}
+
+	for _, kind := range kinds {
+		if multigraph {
+			fmt.Printf("multigraph %s%s\n", muninPluginPrefix, kind)
+		}
+
+		if mode == "config" {
+			graph := muninGraphs[kind]
+
+			fmt.Printf("graph_title %s\n", graph.title)
+			fmt.Printf("graph_vlabel %s\n", graph.unit)
+			fmt.Println("graph_category sensors")
+		}
+
+		for _, feature := range byKind[kind] {
+			field := muninFieldName.ReplaceAllString(feature.chip+"_"+feature.name, "_")
+			input, hasInput := feature.get("input")
+
+			if mode == "config" {
+				fmt.Printf("%s.label %s\n", field, feature.label)
+				fmt.Printf("%s.info %s\n", field, pdl(feature.chip, feature.name))
+
+				if hasInput {
+					if warning, isExpressible := muninRange(input.Warn); isExpressible {
+						fmt.Printf("%s.warning %s\n", field, warning)
+					}
+
+					if critical, isExpressible := muninRange(input.Crit); isExpressible {
+						fmt.Printf("%s.critical %s\n", field, critical)
+					}
+				}
+			} else if hasInput {
+				fmt.Printf("%s.value %s\n", field, strconv.FormatFloat(input.Value, 'f', -1, 64))
+			} else {
+				fmt.Printf("%s.value U\n", field)
+			}
+		}
+	}
+
+	return 0
+}
+
+// muninRange converts a threshold to Munin's "min:max" format if possible.
+func muninRange(threshold OptionalThreshold) (string, bool) {
+	if !threshold.IsSet || threshold.Inverted {
+		return "", false
+	}
+
+	start, end := "", ""
+
+	if threshold.Start != negInf {
+		start = strconv.FormatFloat(threshold.Start, 'f', -1, 64)
+	}
+
+	if threshold.End != posInf {
+		end = strconv.FormatFloat(threshold.End, 'f', -1, 64)
+	}
+
+	return start + ":" + end, true
+}
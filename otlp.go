@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"os"
+	"time"
+)
+
+var otlpEndpoint = flag.String(
+	"otlp-endpoint", "http://localhost:4318/v1/metrics", "Push OTLP metrics to this OTLP/HTTP endpoint",
+)
+
+var otlpInterval = flag.Duration("otlp-interval", 0, "Push OTLP metrics this often instead of once")
+
+// otlpMetric describes the OTLP metric of a kind of features.
+type otlpMetric struct {
+	name string
+	unit string
+}
+
+var otlpMetrics = map[string]otlpMetric{
+	"in":       {"hw.voltage", "V"},
+	"fan":      {"hw.fan.speed", "{rpm}"},
+	"temp":     {"hw.temperature", "Cel"},
+	"curr":     {"hw.current", "A"},
+	"power":    {"hw.power", "W"},
+	"energy":   {"hw.energy", "J"},
+	"humidity": {"hw.humidity", "%"},
+}
+
+// otlpClient pushes the metrics.
+var otlpClient = &http.Client{Timeout: 10 * time.Second}
+
+// otlpExport pushes the inputs as OTLP gauges once or every --otlp-interval.
+func otlpExport(args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "otlp-export takes no arguments")
+		return 3
+	}
+
+	host, errHn := os.Hostname()
+	if errHn != nil {
+		printErrs(map[string]error{"os.Hostname()": errHn})
+		return 3
+	}
+
+	for {
+		start := time.Now()
+
+		_, _, features, errs := readSensors(false)
+		if errs == nil {
+			errs = pushOtlp(host, start, features)
+		}
+
+		if *otlpInterval <= 0 {
+			if errs != nil {
+				printErrs(errs)
+				return 3
+			}
+
+			return 0
+		}
+
+		if errs != nil {
+			printErrs(errs)
+		}
+
+		time.Sleep(*otlpInterval - time.Since(start))
+	}
+}
+
+// pushOtlp sends the features' inputs as OTLP/HTTP protobuf request.
+func pushOtlp(host string, now time.Time, features []featureReading) map[string]error {
+	request, errNR := http.NewRequest(
+		http.MethodPost, *otlpEndpoint, bytes.NewReader(otlpMetricsRequest(host, now, features)),
+	)
+	if errNR != nil {
+		return map[string]error{"http.NewRequest()": errNR}
+	}
+
+	request.Header.Set("Content-Type", "application/x-protobuf")
+
+	response, errDo := otlpClient.Do(request)
+	if errDo != nil {
+		return map[string]error{"POST " + *otlpEndpoint: errDo}
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(response.Body)
+		return map[string]error{"POST " + *otlpEndpoint: fmt.Errorf("%s: %s", response.Status, body)}
+	}
+
+	return nil
+}
+
+// otlpMetricsRequest encodes an ExportMetricsServiceRequest with a gauge per kind of features.
+func otlpMetricsRequest(host string, now time.Time, features []featureReading) []byte {
+	timestamp := uint64(now.UnixNano())
+	kinds := []string{}
+	byKind := map[string][]*featureReading{}
+
+	for i := range features {
+		feature := &features[i]
+
+		if _, hasMetric := otlpMetrics[feature.kind]; hasMetric {
+			if _, seen := byKind[feature.kind]; !seen {
+				kinds = append(kinds, feature.kind)
+			}
+
+			byKind[feature.kind] = append(byKind[feature.kind], feature)
+		}
+	}
+
+	request := protoMessage{}
+
+	request.message(1, func(resourceMetrics *protoMessage) {
+		resourceMetrics.message(1, func(resource *protoMessage) {
+			resource.keyValue(1, "service.name", "check_linux_sensors")
+			resource.keyValue(1, "host.name", host)
+		})
+
+		resourceMetrics.message(2, func(scopeMetrics *protoMessage) {
+			scopeMetrics.message(1, func(scope *protoMessage) {
+				scope.stringField(1, "github.com/Al2Klimov/check_linux_sensors")
+			})
+
+			for _, kind := range kinds {
+				metric := otlpMetrics[kind]
+				dataPoints := []func(*protoMessage){}
+
+				for _, feature := range byKind[kind] {
+					feature := feature
+
+					if input, hasInput := feature.get("input"); hasInput {
+						dataPoints = append(dataPoints, func(dataPoint *protoMessage) {
+							dataPoint.keyValue(7, "chip", feature.chip)
+
+							if feature.adapter != "" {
+								dataPoint.keyValue(7, "adapter", feature.adapter)
+							}
+
+							dataPoint.keyValue(7, "feature", feature.name)
+							dataPoint.keyValue(7, "label", feature.label)
+							dataPoint.fixed64(3, timestamp)
+							dataPoint.fixed64(4, math.Float64bits(input.Value))
+						})
+					}
+				}
+
+				if len(dataPoints) < 1 {
+					continue
+				}
+
+				scopeMetrics.message(2, func(m *protoMessage) {
+					m.stringField(1, metric.name)
+					m.stringField(3, metric.unit)
+
+					m.message(5, func(gauge *protoMessage) {
+						for _, dataPoint := range dataPoints {
+							gauge.message(1, dataPoint)
+						}
+					})
+				})
+			}
+		})
+	})
+
+	return request.Bytes()
+}
+
+// protoMessage encodes a protocol buffers message field by field.
+type protoMessage struct {
+	bytes.Buffer
+}
+
+func (pm *protoMessage) varint(v uint64) {
+	buf := [binary.MaxVarintLen64]byte{}
+	pm.Write(buf[:binary.PutUvarint(buf[:], v)])
+}
+
+func (pm *protoMessage) tag(field, wireType int) {
+	pm.varint(uint64(field<<3 | wireType))
+}
+
+func (pm *protoMessage) fixed64(field int, v uint64) {
+	buf := [8]byte{}
+	binary.LittleEndian.PutUint64(buf[:], v)
+
+	pm.tag(field, 1)
+	pm.Write(buf[:])
+}
+
+func (pm *protoMessage) bytesField(field int, b []byte) {
+	pm.tag(field, 2)
+	pm.varint(uint64(len(b)))
+	pm.Write(b)
+}
+
+func (pm *protoMessage) stringField(field int, s string) {
+	pm.bytesField(field, []byte(s))
+}
+
+func (pm *protoMessage) message(field int, encode func(*protoMessage)) {
+	sub := protoMessage{}
+	encode(&sub)
+	pm.bytesField(field, sub.Bytes())
+}
+
+// keyValue encodes a KeyValue with a string AnyValue.
+func (pm *protoMessage) keyValue(field int, key, value string) {
+	pm.message(field, func(kv *protoMessage) {
+		kv.stringField(1, key)
+		kv.message(2, func(anyValue *protoMessage) {
+			anyValue.stringField(1, value)
+		})
+	})
+}
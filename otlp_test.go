@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/Al2Klimov/go-monplug-utils"
+)
+
+// protoField is a decoded protocol buffers field.
+type protoField struct {
+	number   int
+	wireType int
+	fixed64  uint64
+	bytes    []byte
+}
+
+// decodeProto decodes a message's fields supporting the wire types used by protoMessage.
+func decodeProto(t *testing.T, message []byte) (fields []protoField) {
+	for len(message) > 0 {
+		tag, n := binary.Uvarint(message)
+		if n <= 0 {
+			t.Fatalf("bad tag in %x", message)
+		}
+
+		message = message[n:]
+		field := protoField{number: int(tag >> 3), wireType: int(tag & 7)}
+
+		switch field.wireType {
+		case 1:
+			if len(message) < 8 {
+				t.Fatalf("truncated fixed64 in %x", message)
+			}
+
+			field.fixed64 = binary.LittleEndian.Uint64(message)
+			message = message[8:]
+		case 2:
+			length, n := binary.Uvarint(message)
+			if n <= 0 || uint64(len(message)-n) < length {
+				t.Fatalf("bad length in %x", message)
+			}
+
+			field.bytes = message[n : n+int(length)]
+			message = message[n+int(length):]
+		default:
+			t.Fatalf("unexpected wire type %d", field.wireType)
+		}
+
+		fields = append(fields, field)
+	}
+
+	return
+}
+
+// protoSubmessages returns the length-delimited fields with the given number.
+func protoSubmessages(t *testing.T, message []byte, number int) (submessages [][]byte) {
+	for _, field := range decodeProto(t, message) {
+		if field.number == number {
+			if field.wireType != 2 {
+				t.Fatalf("field %d has wire type %d", number, field.wireType)
+			}
+
+			submessages = append(submessages, field.bytes)
+		}
+	}
+
+	return
+}
+
+// protoString returns the only length-delimited field with the given number as string.
+func protoString(t *testing.T, message []byte, number int) string {
+	submessages := protoSubmessages(t, message, number)
+	if len(submessages) != 1 {
+		t.Fatalf("expected exactly one field %d, got %d", number, len(submessages))
+	}
+
+	return string(submessages[0])
+}
+
+// protoAttributes decodes the KeyValues with string AnyValues with the given field number.
+func protoAttributes(t *testing.T, message []byte, number int) map[string]string {
+	attributes := map[string]string{}
+
+	for _, kv := range protoSubmessages(t, message, number) {
+		attributes[protoString(t, kv, 1)] = protoString(t, protoSubmessages(t, kv, 2)[0], 1)
+	}
+
+	return attributes
+}
+
+func TestPushOtlp(t *testing.T) {
+	var body []byte
+	var contentType, path string
+
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		contentType = r.Header.Get("Content-Type")
+		path = r.URL.Path
+	}))
+
+	defer collector.Close()
+
+	endpoint := *otlpEndpoint
+	*otlpEndpoint = collector.URL + "/v1/metrics"
+	defer func() { *otlpEndpoint = endpoint }()
+
+	features := []featureReading{
+		{
+			chip: "coretemp-isa-0000", adapter: "ISA adapter", name: "temp1", label: "Core 0", kind: "temp",
+			perfdata: PerfdataCollection{{Label: "coretemp-isa-0000::temp1::input", Value: 42.5}},
+		},
+		{
+			chip: "nct6775-isa-0290", name: "fan1", label: "fan1", kind: "fan",
+			perfdata: PerfdataCollection{{Label: "nct6775-isa-0290::fan1::input", Value: 1200}},
+		},
+		{
+			chip: "nct6775-isa-0290", name: "intrusion0", label: "intrusion0", kind: "intrusion",
+			perfdata: PerfdataCollection{{Label: "nct6775-isa-0290::intrusion0::alarm", Value: 0}},
+		},
+	}
+
+	if errs := pushOtlp("example.com", time.Unix(1700000000, 5), features); errs != nil {
+		t.Fatal(errs)
+	}
+
+	if contentType != "application/x-protobuf" || path != "/v1/metrics" {
+		t.Errorf("got POST %s with Content-Type %s", path, contentType)
+	}
+
+	resourceMetrics := protoSubmessages(t, body, 1)
+	if len(resourceMetrics) != 1 {
+		t.Fatalf("expected one ResourceMetrics, got %d", len(resourceMetrics))
+	}
+
+	resource := protoSubmessages(t, resourceMetrics[0], 1)[0]
+	if attributes := protoAttributes(t, resource, 1); attributes["service.name"] != "check_linux_sensors" ||
+		attributes["host.name"] != "example.com" {
+		t.Errorf("unexpected resource attributes: %v", attributes)
+	}
+
+	scopeMetrics := protoSubmessages(t, resourceMetrics[0], 2)[0]
+	metrics := protoSubmessages(t, scopeMetrics, 2)
+
+	expected := []struct {
+		name       string
+		unit       string
+		attributes map[string]string
+		value      float64
+	}{
+		{
+			"hw.temperature", "Cel",
+			map[string]string{"chip": "coretemp-isa-0000", "adapter": "ISA adapter", "feature": "temp1", "label": "Core 0"},
+			42.5,
+		},
+		{
+			"hw.fan.speed", "{rpm}",
+			map[string]string{"chip": "nct6775-isa-0290", "feature": "fan1", "label": "fan1"},
+			1200,
+		},
+	}
+
+	if len(metrics) != len(expected) {
+		t.Fatalf("expected %d metrics, got %d", len(expected), len(metrics))
+	}
+
+	for i, metric := range metrics {
+		if name, unit := protoString(t, metric, 1), protoString(t, metric, 3); name != expected[i].name ||
+			unit != expected[i].unit {
+			t.Errorf("metric #%d: expected %s in %s, got %s in %s", i, expected[i].name, expected[i].unit, name, unit)
+		}
+
+		gauge := protoSubmessages(t, metric, 5)
+		if len(gauge) != 1 {
+			t.Fatalf("metric #%d: expected a gauge", i)
+		}
+
+		dataPoints := protoSubmessages(t, gauge[0], 1)
+		if len(dataPoints) != 1 {
+			t.Fatalf("metric #%d: expected one data point, got %d", i, len(dataPoints))
+		}
+
+		attributes := protoAttributes(t, dataPoints[0], 7)
+		if len(attributes) != len(expected[i].attributes) {
+			t.Errorf("metric #%d: expected attributes %v, got %v", i, expected[i].attributes, attributes)
+		}
+
+		for key, value := range expected[i].attributes {
+			if attributes[key] != value {
+				t.Errorf("metric #%d: expected attributes %v, got %v", i, expected[i].attributes, attributes)
+			}
+		}
+
+		found := 0
+
+		for _, field := range decodeProto(t, dataPoints[0]) {
+			switch field.number {
+			case 3:
+				found++
+
+				if field.wireType != 1 || field.fixed64 != 1700000000000000005 {
+					t.Errorf("metric #%d: bad time_unix_nano %d", i, field.fixed64)
+				}
+			case 4:
+				found++
+
+				if value := math.Float64frombits(field.fixed64); field.wireType != 1 || value != expected[i].value {
+					t.Errorf("metric #%d: expected as_double %v, got %v", i, expected[i].value, value)
+				}
+			}
+		}
+
+		if found != 2 {
+			t.Errorf("metric #%d: expected time_unix_nano and as_double", i)
+		}
+	}
+}
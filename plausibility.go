@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	. "github.com/Al2Klimov/go-monplug-utils"
+	"path"
+	"strconv"
+)
+
+// plausibleRange is the range of physically possible inputs.
+type plausibleRange struct {
+	min, max float64
+}
+
+// plausibleKindRanges are the built-in plausible ranges per feature kind.
+// They exclude typical bogus readings like -128 or 255 deg. C and 65535 RPM.
+var plausibleKindRanges = map[string]plausibleRange{
+	"in":       {-300, 300},
+	"fan":      {0, 30000},
+	"temp":     {-55, 150},
+	"curr":     {-1000, 1000},
+	"power":    {0, 100000},
+	"energy":   {0, posInf},
+	"humidity": {0, 100},
+}
+
+// plausibleFeatureRanges override the ones per kind for "chip::feature" globs.
+var plausibleFeatureRanges []struct {
+	glob string
+	plausibleRange
+}
+
+// parsePlausibleSection interprets a [plausible KIND] or [plausible CHIP::FEATURE-GLOB] section like:
+//
+//	min = 10.8
+//	max = 13.2
+func parsePlausibleSection(section *configSection) error {
+	if section.name == "" {
+		return errorAt(section.line, errors.New("[plausible] requires a feature kind or a chip::feature glob"))
+	}
+
+	pr, isKind := plausibleKindRanges[section.name]
+	if !isKind {
+		if _, errMt := path.Match(section.name, ""); errMt != nil {
+			return errorAt(section.line, errMt)
+		}
+
+		pr = plausibleRange{negInf, posInf}
+	}
+
+	for _, entry := range section.entries {
+		value, errPF := strconv.ParseFloat(entry.value, 64)
+		if errPF != nil {
+			return errorAt(entry.line, errPF)
+		}
+
+		switch entry.key {
+		case "min":
+			pr.min = value
+		case "max":
+			pr.max = value
+		default:
+			return errorAt(entry.line, errors.New("unknown key: "+entry.key))
+		}
+	}
+
+	if pr.min > pr.max {
+		return errorAt(section.line, errors.New("min greater than max"))
+	}
+
+	if isKind {
+		plausibleKindRanges[section.name] = pr
+	} else {
+		plausibleFeatureRanges = append(plausibleFeatureRanges, struct {
+			glob string
+			plausibleRange
+		}{section.name, pr})
+	}
+
+	return nil
+}
+
+// markImplausible strips the thresholds from a feature's input if it's out of the plausible range.
+func markImplausible(chip, feature, kind string, featurePerfdata PerfdataCollection) (isPlausible bool) {
+	pr, hasRange := plausibleKindRanges[kind]
+
+	for _, fr := range plausibleFeatureRanges {
+		if matches, _ := path.Match(fr.glob, pdl(chip, feature)); matches {
+			pr = fr.plausibleRange
+			hasRange = true
+			break
+		}
+	}
+
+	if !hasRange {
+		return true
+	}
+
+	inputLabel := pdl(chip, feature, "input")
+
+	for i := range featurePerfdata {
+		input := &featurePerfdata[i]
+
+		if input.Label == inputLabel && (input.Value < pr.min || input.Value > pr.max) {
+			input.Warn = OptionalThreshold{}
+			input.Crit = OptionalThreshold{}
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	. "github.com/Al2Klimov/go-monplug-utils"
+)
+
+// rateKinds are the feature kinds whose inputs' rates of change are reported.
+var rateKinds = map[string]struct{}{
+	"in": {}, "fan": {}, "temp": {}, "curr": {}, "power": {}, "humidity": {},
+}
+
+// rateThresholds are the warning and critical thresholds per feature kind.
+var rateThresholds = map[string][2]OptionalThreshold{}
+
+// parseRateSection interprets a [rate KIND] section like:
+//
+//	warn = ~:2
+//	crit = ~:5
+func parseRateSection(section *configSection) error {
+	if _, isKind := rateKinds[section.name]; !isKind {
+		return errorAt(section.line, errors.New("[rate] requires one of: in, fan, temp, curr, power, humidity"))
+	}
+
+	thresholds := rateThresholds[section.name]
+
+	for _, entry := range section.entries {
+		threshold, errPT := parseThreshold(entry.value)
+		if errPT != nil {
+			return errorAt(entry.line, errPT)
+		}
+
+		switch entry.key {
+		case "warn":
+			thresholds[0] = threshold
+		case "crit":
+			thresholds[1] = threshold
+		default:
+			return errorAt(entry.line, errors.New("unknown key: "+entry.key))
+		}
+	}
+
+	rateThresholds[section.name] = thresholds
+	return nil
+}
+
+// computeRates derives the rates of change per minute of the features' inputs from the previous readings.
+func computeRates(sf *stateFile, features []featureReading) (rates PerfdataCollection) {
+	for i := range features {
+		feature := &features[i]
+		if _, isRateKind := rateKinds[feature.kind]; !isRateKind {
+			continue
+		}
+
+		input, hasInput := feature.get("input")
+		if !hasInput {
+			continue
+		}
+
+		if last, hasLast := sf.last(input.Label); hasLast {
+			thresholds := rateThresholds[feature.kind]
+
+			rates = append(rates, Perfdata{
+				Label: input.Label + "_rate",
+				Value: (input.Value - last.Value) / sf.now.Sub(last.Time).Minutes(),
+				Warn:  thresholds[0],
+				Crit:  thresholds[1],
+			})
+		}
+	}
+
+	return
+}
+
+// fmtRate formats rates given the units of the features they're of.
+func fmtRate(units map[string]string) func(Perfdata) string {
+	return func(pd Perfdata) string {
+		return fmtNum(pd.Value, units[featureOf(pd.Label)]+"/min")
+	}
+}
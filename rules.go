@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	. "github.com/Al2Klimov/go-monplug-utils"
+)
+
+// rule raises a warning and/or a critical state if the respective expression is true.
+type rule struct {
+	name     string
+	warning  exprNode
+	critical exprNode
+}
+
+var rules []rule
+
+// parseRuleSection interprets a [rule NAME] section like:
+//
+//	critical = cpu_temp_max > 85 and min("nct6775-isa-0290::fan*::input") < 500
+//	warning = cpu_temp_max > 75
+func parseRuleSection(section *configSection) error {
+	if section.name == "" {
+		return errorAt(section.line, errors.New("[rule] requires a name"))
+	}
+
+	for _, r := range rules {
+		if r.name == section.name {
+			return errorAt(section.line, errors.New("duplicate rule: "+section.name))
+		}
+	}
+
+	r := rule{name: section.name}
+
+	for _, entry := range section.entries {
+		expr, errPE := parseExpr(entry.value)
+		if errPE != nil {
+			return errorAt(entry.line, errPE)
+		}
+
+		switch entry.key {
+		case "warning":
+			r.warning = expr
+		case "critical":
+			r.critical = expr
+		default:
+			return errorAt(entry.line, errors.New("unknown key: "+entry.key))
+		}
+	}
+
+	if r.warning == nil && r.critical == nil {
+		return errorAt(section.line, errors.New("rule without warning and critical condition: "+r.name))
+	}
+
+	rules = append(rules, r)
+	return nil
+}
+
+// evaluateRules reports the state of each rule as perfdata (0 = OK, 1 = WARNING, 2 = CRITICAL).
+func evaluateRules(perfdata PerfdataCollection) (ruleStates PerfdataCollection, skipped [][2]string, errs map[string]error) {
+	env := newExprEnv(perfdata)
+
+rules:
+	for _, r := range rules {
+		state := stateOk
+
+		for _, condition := range []struct {
+			expr  exprNode
+			state int
+		}{{r.critical, stateCritical}, {r.warning, stateWarning}} {
+			if condition.expr == nil {
+				continue
+			}
+
+			value, errEv := condition.expr.eval(env)
+			if errEv != nil {
+				if _, isUnavailable := errEv.(*unavailableSensorError); isUnavailable {
+					skipped = append(skipped, [2]string{r.name, errEv.Error()})
+					continue rules
+				}
+
+				return nil, nil, map[string]error{"rule " + r.name: errEv}
+			}
+
+			if value != 0 {
+				state = condition.state
+				break
+			}
+		}
+
+		ruleStates = append(ruleStates, Perfdata{
+			Label: pdl("rule", r.name),
+			Value: float64(state),
+			Warn:  OptionalThreshold{true, false, 0, 0},
+			Crit:  OptionalThreshold{true, false, 0, 1},
+			Min:   OptionalNumber{true, 0},
+			Max:   OptionalNumber{true, 2},
+		})
+	}
+
+	return
+}
+
+func fmtRule(pd Perfdata) string {
+	return stateNames[int(pd.Value)]
+}
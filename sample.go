@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	sensors "github.com/Al2Klimov/go-linux-sensors"
+	"sort"
+	"time"
+)
+
+var samples = flag.Int("samples", 1, "Read each input this many times")
+var sampleInterval = flag.Duration("sample-interval", time.Second, "Wait this long between the samples")
+var sampleReduce = flag.String("sample-reduce", "median", "Reduce the samples by median, min, max or mean")
+
+// sampleKey identifies a subfeature of a chip.
+type sampleKey struct {
+	chip   *sensors.ChipName
+	number int
+}
+
+// sampled holds the reduced samples of the current run for getValue
+// and the spreads (max - min) of the samples per perfdata label.
+var sampled struct {
+	values  map[sampleKey]float64
+	spreads map[string]float64
+}
+
+var sampleReducers = map[string]func(values []float64) float64{
+	"median": func(values []float64) float64 {
+		sorted := append([]float64(nil), values...)
+		sort.Float64s(sorted)
+
+		middle := len(sorted) / 2
+		if len(sorted)%2 == 0 {
+			return (sorted[middle-1] + sorted[middle]) / 2
+		}
+
+		return sorted[middle]
+	},
+	"min": func(values []float64) float64 {
+		min := values[0]
+		for _, value := range values[1:] {
+			if value < min {
+				min = value
+			}
+		}
+
+		return min
+	},
+	"max": func(values []float64) float64 {
+		max := values[0]
+		for _, value := range values[1:] {
+			if value > max {
+				max = value
+			}
+		}
+
+		return max
+	},
+	"mean": func(values []float64) float64 {
+		sum := 0.0
+		for _, value := range values {
+			sum += value
+		}
+
+		return sum / float64(len(values))
+	},
+}
+
+// sampledInputs are the input subfeatures per feature kind which are sampled.
+// Energy counters aren't as they only grow.
+var sampledInputs = map[string]sensors.SubfeatureType{
+	"in":       sensors.SubfeatureInInput,
+	"fan":      sensors.SubfeatureFanInput,
+	"temp":     sensors.SubfeatureTempInput,
+	"curr":     sensors.SubfeatureCurrInput,
+	"power":    sensors.SubfeaturePowerInput,
+	"humidity": sensors.SubfeatureHumidityInput,
+}
+
+func validateSampling() error {
+	if *samples < 1 {
+		return errors.New("--samples must be at least 1")
+	}
+
+	if _, isReducer := sampleReducers[*sampleReduce]; !isReducer {
+		return errors.New("--sample-reduce must be one of median, min, max, mean")
+	}
+
+	return nil
+}
+
+// sampleInputs reads all inputs of the chips --samples times and reduces them for getValue.
+func sampleInputs(chips []*sensors.ChipName) map[string]error {
+	sampled.values = nil
+	sampled.spreads = nil
+
+	if *samples < 2 {
+		return nil
+	}
+
+	type input struct {
+		key   sampleKey
+		label string
+	}
+
+	inputs := []input{}
+
+	for _, chip := range chips {
+		chipNameRaw, errMB := chip.MarshalBinary()
+		if errMB != nil {
+			return map[string]error{"sensors_snprintf_chip_name()": errMB}
+		}
+
+		for _, feature := range chip.GetFeatures() {
+			if typ, isSampled := sampledInputs[featureKind(feature)]; isSampled {
+				if subfeature, hasSubfeature := chip.GetSubfeature(feature, typ); hasSubfeature {
+					inputs = append(inputs, input{
+						sampleKey{chip, subfeature.GetNumber()},
+						pdl(string(chipNameRaw), feature.GetName(), "input"),
+					})
+				}
+			}
+		}
+	}
+
+	values := make([][]float64, len(inputs))
+
+	for i := 0; i < *samples; i++ {
+		if i > 0 {
+			time.Sleep(*sampleInterval)
+		}
+
+		for j, in := range inputs {
+			value, errGV := in.key.chip.GetValue(in.key.number)
+			if errGV != nil {
+				return map[string]error{"sensors_get_value()": errGV}
+			}
+
+			values[j] = append(values[j], value)
+		}
+	}
+
+	sampled.values = make(map[sampleKey]float64, len(inputs))
+	sampled.spreads = make(map[string]float64, len(inputs))
+	reduce := sampleReducers[*sampleReduce]
+
+	for i, in := range inputs {
+		sampled.values[in.key] = reduce(values[i])
+		sampled.spreads[in.label] = sampleReducers["max"](values[i]) - sampleReducers["min"](values[i])
+	}
+
+	return nil
+}
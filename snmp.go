@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lmSensorsMIB is the OID of LM-SENSORS-MIB::lmSensors.
+var lmSensorsMIB = []int{1, 3, 6, 1, 4, 1, 2021, 13, 16}
+
+// snmpTables map the kinds of features to the LM-SENSORS-MIB tables serving them
+// and the factors scaling the inputs to the integers served.
+var snmpTables = map[string]struct {
+	table  int
+	factor float64
+}{
+	"temp":     {2, 1000},
+	"fan":      {3, 1},
+	"in":       {4, 1000},
+	"curr":     {5, 1000},
+	"power":    {5, 1000},
+	"energy":   {5, 1000},
+	"humidity": {5, 1000},
+}
+
+// snmpCacheTimeout is how long the readings are served before the sensors are read again.
+const snmpCacheTimeout = 5 * time.Second
+
+// snmpVar is a variable served via SNMP.
+type snmpVar struct {
+	oid   []int
+	typ   string
+	value string
+}
+
+// snmpPassPersist speaks net-snmp's pass_persist protocol on stdin and stdout.
+func snmpPassPersist(args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "snmp-pass-persist takes no arguments")
+		return 3
+	}
+
+	var vars []snmpVar
+	var readAt time.Time
+
+	in := bufio.NewScanner(os.Stdin)
+	out := bufio.NewWriter(os.Stdout)
+
+	readLine := func() (string, bool) {
+		if in.Scan() {
+			return strings.TrimSpace(in.Text()), true
+		}
+
+		return "", false
+	}
+
+	for {
+		command, hasCommand := readLine()
+		if !hasCommand || command == "" {
+			return 0
+		}
+
+		switch command {
+		case "PING":
+			fmt.Fprintln(out, "PONG")
+		case "get", "getnext":
+			rawOid, hasOid := readLine()
+			if !hasOid {
+				return 0
+			}
+
+			if time.Since(readAt) > snmpCacheTimeout {
+				_, _, features, errs := readSensors(false)
+				if errs != nil {
+					printErrs(errs)
+				}
+
+				vars = lmSensorsVars(features)
+				readAt = time.Now()
+			}
+
+			if v, found := findSnmpVar(vars, parseOid(rawOid), command == "getnext"); found {
+				fmt.Fprintf(out, ".%s\n%s\n%s\n", fmtOid(v.oid), v.typ, v.value)
+			} else {
+				fmt.Fprintln(out, "NONE")
+			}
+		case "set":
+			readLine()
+			readLine()
+			fmt.Fprintln(out, "not-writable")
+		default:
+			fmt.Fprintln(out, "NONE")
+		}
+
+		if errFl := out.Flush(); errFl != nil {
+			return 3
+		}
+	}
+}
+
+// lmSensorsVars arranges the features' inputs as LM-SENSORS-MIB tables in ascending order of the OIDs.
+func lmSensorsVars(features []featureReading) (vars []snmpVar) {
+	for table := 2; table <= 5; table++ {
+		var devices []string
+		var values []float64
+
+		for i := range features {
+			feature := &features[i]
+
+			if st, isServed := snmpTables[feature.kind]; isServed && st.table == table {
+				if input, hasInput := feature.get("input"); hasInput {
+					devices = append(devices, feature.label)
+					values = append(values, input.Value*st.factor)
+				}
+			}
+		}
+
+		for column := 1; column <= 3; column++ {
+			for i := range devices {
+				v := snmpVar{oid: append(append([]int(nil), lmSensorsMIB...), table, 1, column, i+1)}
+
+				switch column {
+				case 1:
+					v.typ, v.value = "integer", strconv.Itoa(i+1)
+				case 2:
+					v.typ, v.value = "string", devices[i]
+				case 3:
+					v.typ, v.value = "gauge", strconv.FormatFloat(
+						math.Max(0, math.Min(math.MaxUint32, math.Round(values[i]))), 'f', 0, 64,
+					)
+				}
+
+				vars = append(vars, v)
+			}
+		}
+	}
+
+	return
+}
+
+// findSnmpVar returns the variable with the given OID or (next) the first one after it.
+func findSnmpVar(vars []snmpVar, oid []int, next bool) (snmpVar, bool) {
+	if oid == nil {
+		return snmpVar{}, false
+	}
+
+	for _, v := range vars {
+		cmp := compareOids(v.oid, oid)
+		if next && cmp > 0 || !next && cmp == 0 {
+			return v, true
+		}
+	}
+
+	return snmpVar{}, false
+}
+
+// parseOid parses e.g. ".1.3.6.1". It returns nil on failure.
+func parseOid(raw string) []int {
+	raw = strings.TrimPrefix(raw, ".")
+	if raw == "" {
+		return []int{}
+	}
+
+	parts := strings.Split(raw, ".")
+	oid := make([]int, len(parts))
+
+	for i, part := range parts {
+		n, errAt := strconv.Atoi(part)
+		if errAt != nil || n < 0 {
+			return nil
+		}
+
+		oid[i] = n
+	}
+
+	return oid
+}
+
+func fmtOid(oid []int) string {
+	parts := make([]string, len(oid))
+	for i, n := range oid {
+		parts[i] = strconv.Itoa(n)
+	}
+
+	return strings.Join(parts, ".")
+}
+
+// compareOids compares OIDs lexicographically.
+func compareOids(a, b []int) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		switch {
+		case a[i] < b[i]:
+			return -1
+		case a[i] > b[i]:
+			return 1
+		}
+	}
+
+	return len(a) - len(b)
+}
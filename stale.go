@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	. "github.com/Al2Klimov/go-monplug-utils"
+	"strconv"
+	"time"
+)
+
+// staleness tells when the input of a feature kind is considered stuck.
+type staleness struct {
+	readings int
+	duration time.Duration
+	state    int
+}
+
+var stalenesses = map[string]staleness{}
+
+// parseStaleSection interprets a [stale KIND] section like:
+//
+//	readings = 12
+//	minutes = 120
+//	state = critical
+//
+// An input is stale once it's reported the same value at least that many times in a row or minutes long.
+// state is either warning (default) or critical.
+func parseStaleSection(section *configSection) error {
+	if errRK := requireInputKind(section); errRK != nil {
+		return errRK
+	}
+
+	s := staleness{state: stateWarning}
+
+	for _, entry := range section.entries {
+		switch entry.key {
+		case "readings", "minutes":
+			count, errPI := strconv.ParseUint(entry.value, 10, 31)
+			if errPI != nil {
+				return errorAt(entry.line, errPI)
+			}
+
+			if count < 2 && entry.key == "readings" || count < 1 {
+				return errorAt(entry.line, errors.New(entry.key+" too small"))
+			}
+
+			if entry.key == "readings" {
+				s.readings = int(count)
+			} else {
+				s.duration = time.Duration(count) * time.Minute
+			}
+		case "state":
+			switch entry.value {
+			case "warning":
+				s.state = stateWarning
+			case "critical":
+				s.state = stateCritical
+			default:
+				return errorAt(entry.line, errors.New("state must be warning or critical"))
+			}
+		default:
+			return errorAt(entry.line, errors.New("unknown key: "+entry.key))
+		}
+	}
+
+	if s.readings == 0 && s.duration == 0 {
+		return errorAt(section.line, errors.New("[stale] requires readings and/or minutes"))
+	}
+
+	stalenesses[section.name] = s
+	return nil
+}
+
+// unchanged tells for how many readings in a row and since when a perfdata has had the same value.
+func (sf *stateFile) unchanged(pd Perfdata) (int, time.Time) {
+	last, hasLast := sf.last(pd.Label)
+	if !hasLast || last.Value != pd.Value {
+		return 1, sf.now
+	}
+
+	repeats, since := last.Repeats, last.Since
+	if repeats < 1 {
+		repeats = 1
+	}
+
+	if since.IsZero() {
+		since = last.Time
+	}
+
+	return repeats + 1, since
+}
+
+// detectStale reports whether each input of the configured kinds is stale (1) or not (0).
+// Only the stale ones are returned for the long output.
+func detectStale(sf *stateFile, features []featureReading) (all, stale PerfdataCollection) {
+	for i := range features {
+		feature := &features[i]
+
+		s, isConfigured := stalenesses[feature.kind]
+		if !isConfigured {
+			continue
+		}
+
+		input, hasInput := feature.get("input")
+		if !hasInput {
+			continue
+		}
+
+		repeats, since := sf.unchanged(input)
+		isStale := s.readings > 0 && repeats >= s.readings || s.duration > 0 && sf.now.Sub(since) >= s.duration
+
+		pd := Perfdata{
+			Label: input.Label + "_stale",
+			Value: exprBool(isStale),
+			Min:   OptionalNumber{true, 0},
+			Max:   OptionalNumber{true, 1},
+		}
+
+		if s.state == stateCritical {
+			pd.Crit = OptionalThreshold{true, false, 0, 0}
+		} else {
+			pd.Warn = OptionalThreshold{true, false, 0, 0}
+		}
+
+		all = append(all, pd)
+
+		if isStale {
+			stale = append(stale, pd)
+		}
+	}
+
+	return
+}
+
+// fmtStale describes a stale input.
+func fmtStale(sf *stateFile, features []featureReading) func(Perfdata) string {
+	inputs := map[string]Perfdata{}
+	for i := range features {
+		if input, hasInput := features[i].get("input"); hasInput {
+			inputs[input.Label+"_stale"] = input
+		}
+	}
+
+	units := featureUnits(features)
+
+	return func(pd Perfdata) string {
+		input := inputs[pd.Label]
+		repeats, since := sf.unchanged(input)
+
+		return fmt.Sprintf(
+			"STALE: %s for %d readings since %s",
+			fmtNum(input.Value, units[featureOf(input.Label)]), repeats, since.Format(time.RFC3339),
+		)
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	. "github.com/Al2Klimov/go-monplug-utils"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var useState = flag.Bool("state", false, "Keep the last readings in a state file in the per-user cache directory")
+var stateFilePath = flag.String("state-file", "", "Keep the last readings in this state file (implies --state)")
+
+// stateRetention is how long the state of perfdata not reported anymore is kept.
+const stateRetention = 7 * 24 * time.Hour
+
+// bootIdFile identifies the current boot of the kernel.
+const bootIdFile = "/proc/sys/kernel/random/boot_id"
+
+// pluginState is what's kept between the plugin's runs.
+type pluginState struct {
+	BootId    string                     `json:"boot_id"`
+	Labels    map[string]*labelState     `json:"labels"`
+	Latched   map[string]*latchedAlarm   `json:"latched,omitempty"`
+	Baselines map[string]*baseline       `json:"baselines,omitempty"`
+	History   map[string][]historySample `json:"history,omitempty"`
+	Extremes  map[string]*extremes       `json:"extremes,omitempty"`
+}
+
+// labelState is what's kept between the plugin's runs per perfdata label.
+type labelState struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+
+	// Repeats is for how many readings in a row Value has been reported since Since.
+	Repeats int       `json:"repeats"`
+	Since   time.Time `json:"since"`
+
+	// State is the one Value has caused according to the thresholds.
+	State int `json:"state"`
+}
+
+// stateFile is a locked and loaded state file.
+type stateFile struct {
+	path     string
+	lock     *os.File
+	now      time.Time
+	data     pluginState
+	rebooted bool
+}
+
+// openState locks and reads the state file. It returns nil if the state is disabled.
+func openState() (*stateFile, map[string]error) {
+	path := *stateFilePath
+	if path == "" {
+		if !*useState {
+			return nil, nil
+		}
+
+		cacheDir, errUCD := os.UserCacheDir()
+		if errUCD != nil {
+			return nil, map[string]error{"os.UserCacheDir()": errUCD}
+		}
+
+		path = filepath.Join(cacheDir, "check_linux_sensors", "state.json")
+	}
+
+	if errMA := os.MkdirAll(filepath.Dir(path), 0700); errMA != nil {
+		return nil, map[string]error{"mkdir()": errMA}
+	}
+
+	lock, errOp := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if errOp != nil {
+		return nil, map[string]error{"open()": errOp}
+	}
+
+	if errFl := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); errFl != nil {
+		lock.Close()
+		return nil, map[string]error{"flock()": errFl}
+	}
+
+	sf := &stateFile{path: path, lock: lock, now: time.Now()}
+
+	if content, errRF := ioutil.ReadFile(path); errRF == nil {
+		if errUm := json.Unmarshal(content, &sf.data); errUm != nil {
+			sf.close()
+			return nil, map[string]error{"json.Unmarshal(" + path + ")": errUm}
+		}
+	} else if !os.IsNotExist(errRF) {
+		sf.close()
+		return nil, map[string]error{"read()": errRF}
+	}
+
+	if sf.data.Labels == nil {
+		sf.data.Labels = map[string]*labelState{}
+	}
+
+	if bootId, errRF := ioutil.ReadFile(bootIdFile); errRF == nil {
+		currentBootId := strings.TrimSpace(string(bootId))
+		sf.rebooted = sf.data.BootId != "" && sf.data.BootId != currentBootId
+		sf.data.BootId = currentBootId
+	}
+
+	return sf, nil
+}
+
+// last returns the previous reading of the given perfdata label if any.
+func (sf *stateFile) last(label string) (*labelState, bool) {
+	if sf == nil {
+		return nil, false
+	}
+
+	ls, hasLabel := sf.data.Labels[label]
+	return ls, hasLabel && ls.Time.Before(sf.now)
+}
+
+// record remembers the current readings and forgets the ones not reported for stateRetention.
+func (sf *stateFile) record(perfdata PerfdataCollection) {
+	for _, pd := range perfdata {
+		repeats, since := sf.unchanged(pd)
+
+		sf.data.Labels[pd.Label] = &labelState{
+			Time:    sf.now,
+			Value:   pd.Value,
+			Repeats: repeats,
+			Since:   since,
+			State:   perfdataState(pd),
+		}
+	}
+
+	for label, ls := range sf.data.Labels {
+		if sf.now.Sub(ls.Time) > stateRetention {
+			delete(sf.data.Labels, label)
+		}
+	}
+
+	for label, bl := range sf.data.Baselines {
+		if sf.now.Sub(bl.Time) > stateRetention {
+			delete(sf.data.Baselines, label)
+		}
+	}
+
+	for label, ex := range sf.data.Extremes {
+		if sf.now.Sub(ex.Time) > stateRetention {
+			delete(sf.data.Extremes, label)
+		}
+	}
+
+	for label, history := range sf.data.History {
+		if len(history) < 1 || sf.now.Sub(history[len(history)-1].time()) > stateRetention {
+			delete(sf.data.History, label)
+		}
+	}
+}
+
+// save replaces the state file atomically.
+func (sf *stateFile) save() map[string]error {
+	content, errMs := json.Marshal(&sf.data)
+	if errMs != nil {
+		return map[string]error{"json.Marshal()": errMs}
+	}
+
+	tmp := sf.path + ".tmp"
+
+	if errWF := ioutil.WriteFile(tmp, content, 0600); errWF != nil {
+		return map[string]error{"write()": errWF}
+	}
+
+	// When run as root (e.g. by reset-history), leave the file to the user running the check.
+	if info, errSt := os.Stat(sf.path); errSt == nil {
+		if stat, isStat := info.Sys().(*syscall.Stat_t); isStat && int(stat.Uid) != os.Geteuid() {
+			if errCh := os.Chown(tmp, int(stat.Uid), int(stat.Gid)); errCh != nil {
+				return map[string]error{"chown()": errCh}
+			}
+		}
+	}
+
+	if errRn := os.Rename(tmp, sf.path); errRn != nil {
+		return map[string]error{"rename()": errRn}
+	}
+
+	return nil
+}
+
+// close releases the lock of the state file.
+func (sf *stateFile) close() {
+	if sf != nil {
+		sf.lock.Close()
+	}
+}
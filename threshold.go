@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	. "github.com/Al2Klimov/go-monplug-utils"
+	"strconv"
+	"strings"
+)
+
+const (
+	stateOk       = 0
+	stateWarning  = 1
+	stateCritical = 2
+)
+
+var stateNames = map[int]string{
+	stateOk:       "OK",
+	stateWarning:  "WARNING",
+	stateCritical: "CRITICAL",
+}
+
+var stateBadges = map[int]string{
+	stateWarning:  ` <b style="color: #f7a000;">WARNING</b>`,
+	stateCritical: ` <b style="color: #f70000;">CRITICAL</b>`,
+}
+
+// thresholdValue makes an OptionalThreshold settable by a CLI flag.
+type thresholdValue OptionalThreshold
+
+var _ flag.Value = (*thresholdValue)(nil)
+
+func (tv *thresholdValue) String() string {
+	if tv == nil || !tv.IsSet {
+		return ""
+	}
+
+	return fmtThreshold(OptionalThreshold(*tv))
+}
+
+func (tv *thresholdValue) Set(s string) error {
+	threshold, errPT := parseThreshold(s)
+	if errPT != nil {
+		return errPT
+	}
+
+	*tv = thresholdValue(threshold)
+	return nil
+}
+
+// thresholdFlag defines a CLI flag taking a threshold in the Nagio$ range format.
+func thresholdFlag(name, usage string) *OptionalThreshold {
+	threshold := &OptionalThreshold{}
+	flag.Var((*thresholdValue)(threshold), name, usage)
+	return threshold
+}
+
+// parseThreshold parses a threshold in the Nagio$ range format ([@]start:end, start defaulting to 0, ~ meaning -inf).
+func parseThreshold(s string) (OptionalThreshold, error) {
+	threshold := OptionalThreshold{IsSet: true}
+	rangeSpec := s
+
+	if strings.HasPrefix(rangeSpec, "@") {
+		threshold.Inverted = true
+		rangeSpec = rangeSpec[1:]
+	}
+
+	if rangeSpec == "" {
+		return OptionalThreshold{}, errors.New("empty threshold")
+	}
+
+	start, end := "0", rangeSpec
+	if colon := strings.Index(rangeSpec, ":"); colon >= 0 {
+		start, end = rangeSpec[:colon], rangeSpec[colon+1:]
+	}
+
+	switch start {
+	case "~":
+		threshold.Start = negInf
+	case "":
+		threshold.Start = 0
+	default:
+		vStart, errPF := strconv.ParseFloat(start, 64)
+		if errPF != nil {
+			return OptionalThreshold{}, errPF
+		}
+
+		threshold.Start = vStart
+	}
+
+	if end == "" {
+		threshold.End = posInf
+	} else {
+		vEnd, errPF := strconv.ParseFloat(end, 64)
+		if errPF != nil {
+			return OptionalThreshold{}, errPF
+		}
+
+		threshold.End = vEnd
+	}
+
+	if threshold.Start > threshold.End {
+		return OptionalThreshold{}, errors.New("threshold start greater than end: " + s)
+	}
+
+	return threshold, nil
+}
+
+func fmtThreshold(threshold OptionalThreshold) string {
+	formatted := ""
+
+	if threshold.Inverted {
+		formatted = "@"
+	}
+
+	switch threshold.Start {
+	case negInf:
+		formatted += "~:"
+	case 0:
+	default:
+		formatted += strconv.FormatFloat(threshold.Start, 'f', -1, 64) + ":"
+	}
+
+	if threshold.End != posInf {
+		formatted += strconv.FormatFloat(threshold.End, 'f', -1, 64)
+	} else if threshold.Start == 0 {
+		formatted += "0:"
+	}
+
+	return formatted
+}
+
+// violates tells whether value is out of threshold's range (or in it if it's inverted).
+func violates(threshold OptionalThreshold, value float64) bool {
+	if !threshold.IsSet {
+		return false
+	}
+
+	inRange := value >= threshold.Start && value <= threshold.End
+	return inRange == threshold.Inverted
+}
+
+func perfdataState(pd Perfdata) int {
+	if violates(pd.Crit, pd.Value) {
+		return stateCritical
+	}
+
+	if violates(pd.Warn, pd.Value) {
+		return stateWarning
+	}
+
+	return stateOk
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	. "github.com/Al2Klimov/go-monplug-utils"
+	"strings"
+)
+
+// virtualSensor is a sensor computed from the other sensors' readings.
+type virtualSensor struct {
+	name string
+	expr exprNode
+	unit string
+	warn OptionalThreshold
+	crit OptionalThreshold
+}
+
+var virtualSensors []virtualSensor
+
+// parseVirtualSection interprets a [virtual] section like:
+//
+//	psu_total = power1 + power2
+//	psu_total.unit = W
+//	psu_total.crit = 500
+func parseVirtualSection(section *configSection) error {
+	if section.name != "" {
+		return errorAt(section.line, errors.New("[virtual] takes no name"))
+	}
+
+	indices := map[string]int{}
+
+	for _, entry := range section.entries {
+		name, attribute := entry.key, ""
+		if dot := strings.Index(entry.key, "."); dot >= 0 {
+			name, attribute = entry.key[:dot], entry.key[dot+1:]
+		}
+
+		if attribute == "" {
+			if _, exists := indices[name]; exists {
+				return errorAt(entry.line, errors.New("duplicate virtual sensor: "+name))
+			}
+
+			expr, errPE := parseExpr(entry.value)
+			if errPE != nil {
+				return errorAt(entry.line, errPE)
+			}
+
+			indices[name] = len(virtualSensors)
+			virtualSensors = append(virtualSensors, virtualSensor{name: name, expr: expr})
+			continue
+		}
+
+		index, exists := indices[name]
+		if !exists {
+			return errorAt(entry.line, errors.New("undefined virtual sensor: "+name))
+		}
+
+		sensor := &virtualSensors[index]
+
+		switch attribute {
+		case "unit":
+			sensor.unit = entry.value
+		case "warn", "crit":
+			threshold, errPT := parseThreshold(entry.value)
+			if errPT != nil {
+				return errorAt(entry.line, errPT)
+			}
+
+			if attribute == "warn" {
+				sensor.warn = threshold
+			} else {
+				sensor.crit = threshold
+			}
+		default:
+			return errorAt(entry.line, errors.New("unknown attribute: "+attribute))
+		}
+	}
+
+	return nil
+}
+
+// evaluateVirtuals computes the virtual sensors in the configured order,
+// so that each one may refer to the previous ones.
+// The ones referring to sensors not read (plausibly) are skipped with the reason.
+func evaluateVirtuals(perfdata PerfdataCollection) (virtuals PerfdataCollection, skipped [][2]string, errs map[string]error) {
+	env := newExprEnv(perfdata)
+
+	for _, sensor := range virtualSensors {
+		value, errEv := sensor.expr.eval(env)
+		if errEv != nil {
+			if _, isUnavailable := errEv.(*unavailableSensorError); isUnavailable {
+				skipped = append(skipped, [2]string{sensor.name, errEv.Error()})
+				continue
+			}
+
+			return nil, nil, map[string]error{"virtual sensor " + sensor.name: errEv}
+		}
+
+		env.set(sensor.name, value)
+
+		virtuals = append(virtuals, Perfdata{
+			Label: sensor.name,
+			Value: value,
+			Warn:  sensor.warn,
+			Crit:  sensor.crit,
+		})
+	}
+
+	return
+}
+
+func fmtVirtual(pd Perfdata) string {
+	for _, sensor := range virtualSensors {
+		if sensor.name == pd.Label {
+			return fmtNum(pd.Value, sensor.unit)
+		}
+	}
+
+	return fmtNum(pd.Value, "")
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// zabbixDiscovery prints the features as Zabbix low-level discovery JSON.
+func zabbixDiscovery(args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "zabbix-discovery takes no arguments")
+		return 3
+	}
+
+	chips, errs := discoverChips()
+	if errs != nil {
+		printErrs(errs)
+		return 3
+	}
+
+	data := []map[string]string{}
+
+	for _, chip := range chips {
+		for _, feature := range chip.Features {
+			data = append(data, map[string]string{
+				"{#CHIP}":    chip.Name,
+				"{#FEATURE}": feature.Name,
+				"{#LABEL}":   feature.Label,
+				"{#TYPE}":    feature.Type,
+			})
+		}
+	}
+
+	content, errMs := json.Marshal(map[string]interface{}{"data": data})
+	if errMs != nil {
+		printErrs(map[string]error{"json.Marshal()": errMs})
+		return 3
+	}
+
+	fmt.Println(string(content))
+	return 0
+}
+
+// zabbixGet prints the value of a single perfdata label, e.g. "coretemp-isa-0000::temp1::input".
+// A "chip::feature" key refers to the feature's input unless there's perfdata labelled like that.
+// The state file (if any) is only read, so that polling single items doesn't advance it.
+func zabbixGet(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: zabbix-get KEY")
+		return 3
+	}
+
+	_, perfdata, _, errs := readSensors(false)
+	if errs != nil {
+		printErrs(errs)
+		return 3
+	}
+
+	for _, key := range []string{args[0], args[0] + "::input"} {
+		for _, pd := range perfdata {
+			if pd.Label == key {
+				fmt.Println(strconv.FormatFloat(pd.Value, 'f', -1, 64))
+				return 0
+			}
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "no such key: %s\n", args[0])
+	return 3
+}